@@ -0,0 +1,166 @@
+package logparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AccessLogCommon   = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`
+	AccessLogCombined = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+
+	accessLogTimeLocal = "02/Jan/2006:15:04:05 -0700"
+)
+
+var (
+	accessLogVariable = regexp.MustCompile(`\$[a-z0-9_]+`)
+)
+
+// AccessLogDecoder parses access logs written using an nginx `log_format` string.
+// AccessLogCommon and AccessLogCombined also match the Apache common and combined formats.
+// The decoder derives the level from the status class and sets a pattern built of the method,
+// the normalized route and the status, so requests are grouped by route template.
+type AccessLogDecoder struct {
+	re   *regexp.Regexp
+	vars []string
+}
+
+func NewAccessLogDecoder(format string) (*AccessLogDecoder, error) {
+	d := &AccessLogDecoder{}
+	expr := "^"
+	pos := 0
+	for _, loc := range accessLogVariable.FindAllStringIndex(format, -1) {
+		expr += regexp.QuoteMeta(format[pos:loc[0]])
+		if loc[1] == len(format) {
+			expr += "(.*)"
+		} else {
+			expr += "(.*?)"
+		}
+		d.vars = append(d.vars, format[loc[0]+1:loc[1]])
+		pos = loc[1]
+	}
+	if len(d.vars) == 0 {
+		return nil, fmt.Errorf("no variables in log format: %s", format)
+	}
+	expr += regexp.QuoteMeta(format[pos:])
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log format %s: %w", format, err)
+	}
+	d.re = re
+	return d, nil
+}
+
+func (d *AccessLogDecoder) Decode(src string) (string, error) {
+	entry, err := d.DecodeEntry(LogEntry{Content: src})
+	if err != nil {
+		return "", err
+	}
+	return entry.Content, nil
+}
+
+func (d *AccessLogDecoder) DecodeEntry(entry LogEntry) (LogEntry, error) {
+	match := d.re.FindStringSubmatch(entry.Content)
+	if match == nil {
+		return entry, fmt.Errorf("unexpected access log entry format: %s", entry.Content)
+	}
+	fields := map[string]string{}
+	for i, name := range d.vars {
+		v := match[i+1]
+		if v == "-" || v == "" {
+			continue
+		}
+		switch name {
+		case "remote_addr":
+			fields["client"] = v
+		case "request":
+			parts := strings.Fields(v)
+			if len(parts) < 2 {
+				return entry, fmt.Errorf("unexpected request format: %s", v)
+			}
+			fields["method"], fields["path"] = parts[0], parts[1]
+		case "request_method":
+			fields["method"] = v
+		case "request_uri", "uri":
+			fields["path"] = v
+		case "status":
+			fields["status"] = v
+		case "body_bytes_sent", "bytes_sent":
+			fields["bytes"] = v
+		case "request_time":
+			fields["latency"] = v
+		case "upstream_response_time":
+			if _, ok := fields["latency"]; !ok {
+				fields["latency"] = v
+			}
+		case "time_local":
+			if ts, err := time.Parse(accessLogTimeLocal, v); err == nil {
+				entry.Timestamp = ts
+			}
+		case "time_iso8601":
+			if ts, err := time.Parse(time.RFC3339, v); err == nil {
+				entry.Timestamp = ts
+			}
+		}
+	}
+	status, err := strconv.Atoi(fields["status"])
+	if err != nil {
+		return entry, fmt.Errorf("invalid status in access log entry: %s", entry.Content)
+	}
+	switch {
+	case status >= 500:
+		entry.Level = LevelError
+	case status >= 400:
+		entry.Level = LevelWarning
+	default:
+		entry.Level = LevelInfo
+	}
+	// the request line may contain level words, e.g. GET /error
+	entry.LevelFixed = true
+	if path, ok := fields["path"]; ok {
+		fields["route"] = normalizeRoute(path)
+		entry.Pattern = fields["method"] + " " + fields["route"] + " " + fields["status"]
+	}
+	entry.Fields = fields
+	return entry, nil
+}
+
+// normalizeRoute strips the query string and replaces identifiers in the path with `{id}`:
+// /api/v1/users/42/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8?full=1 -> /api/v1/users/{id}/orders/{id}
+func normalizeRoute(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if isRouteIdentifier(s) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isRouteIdentifier(s string) bool {
+	var digits int
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	switch {
+	case digits == 0:
+		return false
+	case digits == len(s):
+		return true
+	case uuid.MatchString(s):
+		return true
+	case len(s) >= 8 && (hex.MatchString(s) || hexWithPrefix.MatchString(s)):
+		return true
+	case len(s) >= 16 && digits*4 >= len(s):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package logparser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogDecoder(t *testing.T) {
+	d, err := NewAccessLogDecoder(AccessLogCombined)
+	require.NoError(t, err)
+
+	line := `10.42.0.21 - frank [30/Oct/2023:11:55:47 +0000] "GET /api/v1/users/42/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8?full=1 HTTP/1.1" 502 157 "-" "curl/8.1.2"`
+	entry, err := d.DecodeEntry(LogEntry{Content: line})
+	require.NoError(t, err)
+	assert.Equal(t, line, entry.Content)
+	assert.Equal(t, LevelError, entry.Level)
+	assert.Equal(t, time.Date(2023, 10, 30, 11, 55, 47, 0, time.UTC), entry.Timestamp.UTC())
+	assert.Equal(t, "GET /api/v1/users/{id}/orders/{id} 502", entry.Pattern)
+	assert.Equal(t, map[string]string{
+		"client": "10.42.0.21",
+		"method": "GET",
+		"path":   "/api/v1/users/42/orders/6ba7b810-9dad-11d1-80b4-00c04fd430c8?full=1",
+		"route":  "/api/v1/users/{id}/orders/{id}",
+		"status": "502",
+		"bytes":  "157",
+	}, entry.Fields)
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `::1 - - [30/Oct/2023:11:55:47 +0000] "POST /login HTTP/1.1" 401 0 "https://example.com/" "Mozilla/5.0 (X11; Linux x86_64)"`})
+	require.NoError(t, err)
+	assert.Equal(t, LevelWarning, entry.Level)
+	assert.Equal(t, "POST /login 401", entry.Pattern)
+
+	d, err = NewAccessLogDecoder(AccessLogCommon)
+	require.NoError(t, err)
+	entry, err = d.DecodeEntry(LogEntry{Content: `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`})
+	require.NoError(t, err)
+	assert.Equal(t, LevelInfo, entry.Level)
+	assert.Equal(t, "GET /apache_pb.gif 200", entry.Pattern)
+
+	d, err = NewAccessLogDecoder(`$time_iso8601 $request_method $uri $status $bytes_sent rt=$request_time urt=$upstream_response_time`)
+	require.NoError(t, err)
+	entry, err = d.DecodeEntry(LogEntry{Content: `2023-10-30T11:55:47+00:00 DELETE /carts/a3f9c2d1e0b4 500 12 rt=0.113 urt=0.110`})
+	require.NoError(t, err)
+	assert.Equal(t, LevelError, entry.Level)
+	assert.Equal(t, "DELETE /carts/{id} 500", entry.Pattern)
+	assert.Equal(t, "0.113", entry.Fields["latency"])
+	assert.Equal(t, time.Date(2023, 10, 30, 11, 55, 47, 0, time.UTC), entry.Timestamp.UTC())
+
+	_, err = d.Decode(`not an access log line`)
+	assert.Error(t, err)
+	_, err = NewAccessLogDecoder(`no variables`)
+	assert.Error(t, err)
+}
+
+func TestAccessLogDecoderLevel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d, err := NewAccessLogDecoder(AccessLogCommon)
+	require.NoError(t, err)
+	// the status decides the level even if the content is guessed first
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithLevelPolicy(ContentFirstLevelPolicy...))
+	for line, level := range map[string]Level{
+		`10.0.0.1 - - [30/Oct/2023:11:55:47 +0000] "POST /v1/jobs/42:debug HTTP/1.1" 500 12`: LevelError,
+		`10.0.0.1 - - [30/Oct/2023:11:55:47 +0000] "GET /v1/reports:error HTTP/1.1" 200 12`:  LevelInfo,
+		`10.0.0.1 - - [30/Oct/2023:11:55:47 +0000] "GET /v1/jobs:info HTTP/1.1" 404 12`:      LevelWarning,
+	} {
+		entry, err := d.DecodeEntry(LogEntry{Content: line})
+		require.NoError(t, err)
+		assert.Equal(t, level, entry.Level, line)
+		m.Add(entry)
+		msg := <-m.Messages
+		assert.Equal(t, level, msg.Level, line)
+		assert.Equal(t, LevelSourceField, msg.LevelSource, line)
+	}
+}
+
+func TestNormalizeRoute(t *testing.T) {
+	assert.Equal(t, "/", normalizeRoute("/"))
+	assert.Equal(t, "/api/v2/items", normalizeRoute("/api/v2/items?page=3"))
+	assert.Equal(t, "/items/{id}/reviews", normalizeRoute("/items/123/reviews#top"))
+	assert.Equal(t, "/blobs/{id}", normalizeRoute("/blobs/0x1f2e3d4c"))
+	assert.Equal(t, "/files/cafe", normalizeRoute("/files/cafe"))
+	assert.Equal(t, "/sessions/{id}", normalizeRoute("/sessions/k3j4h5g6f7d8s9a0q1w2"))
+}
@@ -0,0 +1,203 @@
+package logparser
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatDocker = "docker"
+	FormatCri    = "cri"
+	FormatJson   = "json"
+	FormatSyslog = "syslog"
+	FormatLogfmt = "logfmt"
+	FormatPlain  = "plain"
+
+	autoDecoderMaxErrors = 10
+)
+
+type formatDetector struct {
+	format  string
+	decoder Decoder
+	score   func(line string) int
+}
+
+// detectors are ordered by specificity: in the event of a tie, the first one wins
+var formatDetectors = []formatDetector{
+	{format: FormatDocker, decoder: DockerJsonDecoder{}, score: scoreDocker},
+	{format: FormatCri, decoder: CriDecoder{}, score: scoreCri},
+	{format: FormatJson, decoder: JsonDecoder{}, score: scoreJson},
+	{format: FormatSyslog, decoder: SyslogDecoder{}, score: scoreSyslog},
+	{format: FormatLogfmt, decoder: LogfmtDecoder{}, score: scoreLogfmt},
+	{format: FormatPlain, decoder: plainDecoder{}, score: func(string) int { return 1 }},
+}
+
+// AutoDecoder scores the first sampleSize lines against the known formats and then locks onto the best one.
+// If the chosen decoder fails on autoDecoderMaxErrors lines in a row, the detection starts over.
+type AutoDecoder struct {
+	sampleSize int
+
+	lock    sync.Mutex
+	scores  []int
+	sampled int
+	locked  bool
+	current int
+	errors  int
+}
+
+func NewAutoDecoder(sampleSize int) *AutoDecoder {
+	d := &AutoDecoder{sampleSize: sampleSize}
+	d.reset()
+	return d
+}
+
+// Format returns the name of the format the decoder has locked onto,
+// or the current leader if the detection is still in progress.
+func (d *AutoDecoder) Format() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.locked && d.sampled == 0 {
+		return ""
+	}
+	return formatDetectors[d.current].format
+}
+
+func (d *AutoDecoder) Decode(src string) (string, error) {
+	entry, err := d.DecodeEntry(LogEntry{Content: src})
+	if err != nil {
+		return "", err
+	}
+	return entry.Content, nil
+}
+
+func (d *AutoDecoder) DecodeEntry(entry LogEntry) (LogEntry, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !d.locked {
+		best, bestScore := 0, 0
+		for i, fd := range formatDetectors {
+			s := fd.score(entry.Content)
+			d.scores[i] += s
+			if s > bestScore {
+				best, bestScore = i, s
+			}
+			if d.scores[i] > d.scores[d.current] {
+				d.current = i
+			}
+		}
+		d.sampled++
+		if d.sampled >= d.sampleSize {
+			d.locked = true
+		}
+		return decode(formatDetectors[best].decoder, entry)
+	}
+
+	res, err := decode(formatDetectors[d.current].decoder, entry)
+	if err != nil {
+		d.errors++
+		if d.errors >= autoDecoderMaxErrors {
+			d.reset()
+		}
+		return res, err
+	}
+	d.errors = 0
+	return res, nil
+}
+
+func (d *AutoDecoder) reset() {
+	d.scores = make([]int, len(formatDetectors))
+	d.sampled = 0
+	d.locked = false
+	d.current = 0
+	d.errors = 0
+}
+
+type plainDecoder struct{}
+
+func (d plainDecoder) Decode(src string) (string, error) {
+	return src, nil
+}
+
+func scoreDocker(line string) int {
+	if !strings.HasPrefix(line, "{") {
+		return 0
+	}
+	obj := struct {
+		Log *string
+	}{}
+	if err := json.Unmarshal([]byte(line), &obj); err != nil || obj.Log == nil {
+		return 0
+	}
+	return 4
+}
+
+// 2016-10-06T00:17:09.669794202Z stdout F log content
+func scoreCri(line string) int {
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) < 4 {
+		return 0
+	}
+	if parts[1] != "stdout" && parts[1] != "stderr" {
+		return 0
+	}
+	if parts[2] != "F" && parts[2] != "P" {
+		return 0
+	}
+	if _, err := time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return 0
+	}
+	return 4
+}
+
+func scoreJson(line string) int {
+	if !strings.HasPrefix(line, "{") {
+		return 0
+	}
+	entry, err := JsonDecoder{}.DecodeEntry(LogEntry{Content: line})
+	if err != nil {
+		return 0
+	}
+	if entry.Content != line {
+		return 3
+	}
+	return 2
+}
+
+func scoreSyslog(line string) int {
+	if _, err := (SyslogDecoder{}).Decode(line); err != nil {
+		return 0
+	}
+	if _, _, hasPri := parseSyslogPriority(line); hasPri {
+		return 3
+	}
+	return 2
+}
+
+func scoreLogfmt(line string) int {
+	pairs, err := parseLogfmt(line)
+	if err != nil {
+		return 0
+	}
+	var withValue int
+	var primary bool
+	for _, p := range pairs {
+		if p.value == "" {
+			continue
+		}
+		withValue++
+		switch p.key {
+		case "msg", "message", "level", "lvl":
+			primary = true
+		}
+	}
+	switch {
+	case primary && withValue >= 2:
+		return 3
+	case withValue >= 3:
+		return 2
+	}
+	return 0
+}
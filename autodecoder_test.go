@@ -0,0 +1,67 @@
+package logparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoDecoder(t *testing.T) {
+	samples := map[string][]string{
+		FormatDocker: {
+			`{"log":"I0215 12:33:07.230967 foo\n","stream":"stderr","time":"2024-02-15T12:33:07.231Z"}`,
+			`{"log":"bar\n","stream":"stdout","time":"2024-02-15T12:33:07.232Z"}`,
+		},
+		FormatCri: {
+			`2016-10-06T00:17:09.669794202Z stdout F first`,
+			`2016-10-06T00:17:09.669794203Z stderr P second`,
+		},
+		FormatJson: {
+			`{"level":"info","msg":"started","port":8080}`,
+			`{"level":"error","msg":"failed"}`,
+		},
+		FormatSyslog: {
+			`<34>Oct 11 22:14:15 mymachine su: 'su root' failed`,
+			`<165>1 2003-10-11T22:14:15.003Z host app - - - msg`,
+		},
+		FormatLogfmt: {
+			`time=2024-01-15T10:20:30Z level=info msg="started" port=8080`,
+			`level=error msg=failed`,
+		},
+		FormatPlain: {
+			`2024-01-15 10:20:30 INFO started on port 8080`,
+			`2024-01-15 10:20:31 ERROR failed a=b`,
+		},
+	}
+	for format, lines := range samples {
+		d := NewAutoDecoder(len(lines))
+		assert.Equal(t, "", d.Format())
+		for _, l := range lines {
+			_, err := d.Decode(l)
+			require.NoError(t, err, format)
+		}
+		assert.Equal(t, format, d.Format())
+	}
+
+	d := NewAutoDecoder(2)
+	for _, l := range samples[FormatDocker] {
+		_, err := d.Decode(l)
+		require.NoError(t, err)
+	}
+	content, err := d.Decode(`{"log":"baz\n","stream":"stdout"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "baz\n", content)
+	assert.Equal(t, FormatDocker, d.Format())
+	for i := 0; i < autoDecoderMaxErrors; i++ {
+		_, err = d.Decode(samples[FormatCri][0])
+		assert.Error(t, err)
+	}
+	content, err = d.Decode(samples[FormatCri][0])
+	require.NoError(t, err)
+	assert.Equal(t, "first", content)
+	content, err = d.Decode(samples[FormatCri][1])
+	require.NoError(t, err)
+	assert.Equal(t, "second", content)
+	assert.Equal(t, FormatCri, d.Format())
+}
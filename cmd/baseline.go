@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	logparser "github.com/prs-io/plexus-logparser"
+)
+
+type baselinePattern struct {
+	Level   string `json:"level"`
+	Hash    string `json:"hash"`
+	Pattern string `json:"pattern"`
+	Sample  string `json:"sample"`
+	Count   int    `json:"count"`
+}
+
+type baseline struct {
+	Patterns []baselinePattern `json:"patterns"`
+}
+
+type baselineViolation struct {
+	counter  logparser.LogCounter
+	baseline *baselinePattern
+}
+
+func writeBaseline(path string, counters []logparser.LogCounter) error {
+	b := baseline{Patterns: []baselinePattern{}}
+	for _, c := range counters {
+		if c.Sample == "" {
+			continue
+		}
+		b.Patterns = append(b.Patterns, baselinePattern{Level: c.Level.String(), Hash: c.Hash, Pattern: c.Pattern, Sample: c.Sample, Count: c.Messages})
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return nil
+}
+
+func readBaseline(path string) (*baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	b := &baseline{}
+	if err = json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// compare returns the patterns of warning level and above that are missing from the baseline,
+// and, if maxGrowth > 0, the known patterns whose count exceeds maxGrowth times the count in the baseline
+func (b *baseline) compare(counters []logparser.LogCounter, maxGrowth float64) []baselineViolation {
+	known := map[string]*baselinePattern{}
+	for i := range b.Patterns {
+		known[b.Patterns[i].Level+"/"+b.Patterns[i].Hash] = &b.Patterns[i]
+	}
+	var res []baselineViolation
+	for _, c := range counters {
+		if c.Sample == "" || c.Level.SeverityNumber() < logparser.LevelWarning.SeverityNumber() {
+			continue
+		}
+		bp := known[c.Level.String()+"/"+c.Hash]
+		if bp == nil {
+			bp = b.similar(c)
+		}
+		switch {
+		case bp == nil:
+			res = append(res, baselineViolation{counter: c})
+		case maxGrowth > 0 && float64(c.Messages) > float64(bp.Count)*maxGrowth:
+			res = append(res, baselineViolation{counter: c, baseline: bp})
+		}
+	}
+	return res
+}
+
+// similar finds a pattern the parser would have merged the counter into: a counter is keyed by the hash
+// of the first variant seen, so the hash depends on the order of the messages
+func (b *baseline) similar(c logparser.LogCounter) *baselinePattern {
+	pattern := logparser.NewPatternFromWords(c.Pattern)
+	for i := range b.Patterns {
+		bp := &b.Patterns[i]
+		if bp.Level == c.Level.String() && logparser.NewPatternFromWords(bp.Pattern).WeakEqual(pattern) {
+			return bp
+		}
+	}
+	return nil
+}
+
+func reportViolations(w io.Writer, violations []baselineViolation, screenWidth int) {
+	fmt.Fprintf(w, "%d patterns not matching the baseline:\n", len(violations))
+	for _, v := range violations {
+		line := firstLine(v.counter.Sample, screenWidth)
+		if v.baseline == nil {
+			fmt.Fprintf(w, "  new %s pattern (%d messages): %s\n", v.counter.Level, v.counter.Messages, line)
+			continue
+		}
+		fmt.Fprintf(w, "  %s pattern grew from %d to %d messages: %s\n", v.counter.Level, v.baseline.Count, v.counter.Messages, line)
+	}
+}
+
+func firstLine(s string, width int) string {
+	s, _, _ = strings.Cut(s, "\n")
+	if width > 0 && len(s) > width {
+		s = s[:width] + "..."
+	}
+	return s
+}
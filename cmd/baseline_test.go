@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	logparser "github.com/prs-io/plexus-logparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counter(level logparser.Level, sample string, messages int) logparser.LogCounter {
+	p := logparser.NewPattern(sample)
+	return logparser.LogCounter{Level: level, Hash: p.Hash(), Pattern: p.String(), Sample: sample, Messages: messages}
+}
+
+func TestBaselineCompare(t *testing.T) {
+	b := &baseline{Patterns: []baselinePattern{
+		{Level: "error", Hash: logparser.NewPattern("db connection refused").Hash(), Pattern: logparser.NewPattern("db connection refused").String(), Count: 2},
+		{Level: "warning", Hash: logparser.NewPattern("slow query").Hash(), Pattern: logparser.NewPattern("slow query").String(), Count: 1},
+	}}
+
+	assert.Empty(t, b.compare([]logparser.LogCounter{
+		counter(logparser.LevelError, "db connection refused", 2),
+		counter(logparser.LevelWarning, "slow query", 5),
+		// below warning
+		counter(logparser.LevelInfo, "server started", 1),
+		// not sampled
+		{Level: logparser.LevelError, Messages: 1},
+	}, 0))
+
+	// the counter is keyed by the first variant seen, which depends on the order of the messages
+	assert.Empty(t, b.compare([]logparser.LogCounter{counter(logparser.LevelError, "cache connection refused", 2)}, 0))
+
+	v := b.compare([]logparser.LogCounter{
+		counter(logparser.LevelError, "disk is full", 1),
+		counter(logparser.LevelWarning, "db connection refused", 1),
+		counter(logparser.LevelError, "db connection refused", 5),
+		counter(logparser.LevelWarning, "slow query", 2),
+	}, 2)
+	require.Len(t, v, 3)
+	assert.Equal(t, "disk is full", v[0].counter.Sample)
+	assert.Nil(t, v[0].baseline)
+	// the same pattern at another level is a new one
+	assert.Equal(t, logparser.LevelWarning, v[1].counter.Level)
+	assert.Nil(t, v[1].baseline)
+	assert.Equal(t, 5, v[2].counter.Messages)
+	assert.Equal(t, 2, v[2].baseline.Count)
+}
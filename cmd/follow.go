@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logparser "github.com/prs-io/plexus-logparser"
+)
+
+const (
+	followPollInterval = 250 * time.Millisecond
+)
+
+// follow tails the files (or stdin) and redraws the top patterns every interval until ctx is done,
+// then it prints the summary. The top patterns are only redrawn in the text format to keep stdout parseable.
+func follow(ctx context.Context, paths []string, ch chan<- logparser.LogEntry, parser *logparser.Parser, format string, interval time.Duration, top, screenWidth int) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	wg := sync.WaitGroup{}
+	for _, p := range paths {
+		wg.Add(1)
+		if p == "-" {
+			// a blocking read of stdin can't be interrupted, so the lines are forwarded until ctx is done
+			lines := make(chan logparser.LogEntry)
+			go func() {
+				if err := read(os.Stdin, stdinSource, lines); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case e := <-lines:
+						ch <- e
+					}
+				}
+			}()
+			continue
+		}
+		tl, err := newTailer(p)
+		if err != nil {
+			wg.Done()
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		go func() {
+			defer wg.Done()
+			if err := tl.run(ctx, ch); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	t := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	prev := map[counterKey]int{}
+	last := t
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			close(ch)
+			<-parser.Done()
+			counters := parser.GetCounters()
+			order(counters)
+			writeOrExit(format, counters, parser.GetStats(), screenWidth, time.Since(t))
+			return
+		case now := <-ticker.C:
+			if format != formatText {
+				continue
+			}
+			counters := parser.GetCounters()
+			redraw(counters, prev, now.Sub(last), time.Since(t), top, screenWidth)
+			prev = map[counterKey]int{}
+			for _, c := range counters {
+				prev[counterKey{level: c.Level, hash: c.Hash}] = c.Messages
+			}
+			last = now
+		}
+	}
+}
+
+// tailer reads the lines appended to a file like tail -F: it starts at the end of the file,
+// reopens the file if it has been rotated and starts over if it has been truncated.
+type tailer struct {
+	path    string
+	f       *os.File
+	offset  int64
+	partial string
+}
+
+// newTailer opens the file at its end, the lines appended from now on are read by run
+func newTailer(path string) (*tailer, error) {
+	t := &tailer{path: path}
+	if err := t.open(true); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tailer) open(atEnd bool) error {
+	var err error
+	if t.f, err = os.Open(t.path); err != nil {
+		t.f = nil
+		return err
+	}
+	t.offset = 0
+	if atEnd {
+		if t.offset, err = t.f.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tailer) emit(data string, ch chan<- logparser.LogEntry) {
+	data = t.partial + data
+	for {
+		i := strings.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		ch <- logparser.LogEntry{Timestamp: time.Now(), Content: data[:i], Level: logparser.LevelUnknown, Source: t.path}
+		data = data[i+1:]
+	}
+	t.partial = data
+}
+
+// run polls the file until ctx is done and closes it
+func (t *tailer) run(ctx context.Context, ch chan<- logparser.LogEntry) error {
+	defer func() {
+		if t.f != nil {
+			t.f.Close()
+		}
+	}()
+	buf := make([]byte, 64*1024)
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+	for {
+		if t.f != nil {
+			for {
+				n, err := t.f.Read(buf)
+				t.offset += int64(n)
+				t.emit(string(buf[:n]), ch)
+				if errors.Is(err, io.EOF) || n == 0 {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", t.path, err)
+				}
+			}
+			current, err := t.f.Stat()
+			if err != nil {
+				return err
+			}
+			switch fi, err := os.Stat(t.path); {
+			case err != nil || !os.SameFile(fi, current):
+				// rotated: the rest of the old file has been read, the new one is read from the beginning
+				if t.partial != "" {
+					t.emit("\n", ch)
+				}
+				t.f.Close()
+				t.f = nil
+			case fi.Size() < t.offset:
+				// truncated
+				t.partial = ""
+				if t.offset, err = t.f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+			}
+		}
+		if t.f == nil {
+			_ = t.open(false)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type counterKey struct {
+	level logparser.Level
+	hash  string
+}
+
+// redraw prints the top patterns in place with their rates since the previous redraw
+func redraw(counters []logparser.LogCounter, prev map[counterKey]int, interval, duration time.Duration, top, screenWidth int) {
+	rates := map[counterKey]float64{}
+	total, totalRate := 0, 0.0
+	for _, c := range counters {
+		k := counterKey{level: c.Level, hash: c.Hash}
+		rates[k] = float64(c.Messages-prev[k]) / interval.Seconds()
+		total += c.Messages
+		totalRate += rates[k]
+	}
+	patterns := counters[:0:0]
+	for _, c := range counters {
+		if c.Sample != "" {
+			patterns = append(patterns, c)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		ri, rj := rates[counterKey{level: patterns[i].Level, hash: patterns[i].Hash}], rates[counterKey{level: patterns[j].Level, hash: patterns[j].Hash}]
+		if ri == rj {
+			return patterns[i].Messages > patterns[j].Messages
+		}
+		return ri > rj
+	})
+	if len(patterns) > top {
+		patterns = patterns[:top]
+	}
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&b, "%d messages in %s, %.1f/s\n\n", total, duration.Truncate(time.Second), totalRate)
+	max := 0
+	for _, c := range patterns {
+		if c.Messages > max {
+			max = c.Messages
+		}
+	}
+	barWidth := 20
+	messagesNumFmt := fmt.Sprintf("%%%dd", len(strconv.Itoa(max)))
+	for _, c := range patterns {
+		w := c.Messages * barWidth / max
+		bar := strings.Repeat("▇", w+1) + strings.Repeat(" ", barWidth-w)
+		prefix := fmt.Sprintf("%s "+messagesNumFmt+" %7.1f/s ", bar, c.Messages, rates[counterKey{level: c.Level, hash: c.Hash}])
+		line, _, _ := strings.Cut(c.Sample, "\n")
+		if lineWidth := screenWidth - (len(prefix) - len(bar) + barWidth + 1); lineWidth > 0 && len(line) > lineWidth {
+			line = line[:lineWidth] + "..."
+		}
+		b.WriteString(colorize(c.Level, "%s", prefix) + line + "\n")
+	}
+	fmt.Print(b.String())
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logparser "github.com/prs-io/plexus-logparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan logparser.LogEntry) string {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e.Content
+	case <-time.After(5 * time.Second):
+		t.Fatal("no line received")
+	}
+	return ""
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("written before\n"), 0644))
+	tl, err := newTailer(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan logparser.LogEntry, 10)
+	done := make(chan error)
+	go func() { done <- tl.run(ctx, ch) }()
+
+	appendFile(t, path, "first\n")
+	assert.Equal(t, "first", receive(t, ch))
+
+	// rotated: the rest of the old file is read before the new file, which is read from the beginning
+	appendFile(t, path, "last line of the old file")
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, os.WriteFile(path, []byte("first line of the new file\nsecond line of the new file\n"), 0644))
+	assert.Equal(t, "last line of the old file", receive(t, ch))
+	assert.Equal(t, "first line of the new file", receive(t, ch))
+	assert.Equal(t, "second line of the new file", receive(t, ch))
+
+	// truncated: the size is less than the offset, the file is read from the beginning
+	require.NoError(t, os.WriteFile(path, []byte("after truncation\n"), 0644))
+	assert.Equal(t, "after truncation", receive(t, ch))
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Empty(t, ch)
+}
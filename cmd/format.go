@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	logparser "github.com/prs-io/plexus-logparser"
+)
+
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatNDJSON   = "ndjson"
+	formatCSV      = "csv"
+	formatMarkdown = "markdown"
+)
+
+var formats = []string{formatText, formatJSON, formatNDJSON, formatCSV, formatMarkdown}
+
+func validFormat(format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+type patternRecord struct {
+	Level      string         `json:"level"`
+	Hash       string         `json:"hash"`
+	Pattern    string         `json:"pattern"`
+	Sample     string         `json:"sample"`
+	Count      int            `json:"count"`
+	Percentage float64        `json:"percentage"`
+	Sources    map[string]int `json:"sources,omitempty"`
+}
+
+type levelRecord struct {
+	Level string `json:"level"`
+	Count int    `json:"count"`
+}
+
+type statsRecord struct {
+	Messages        int            `json:"messages"`
+	Entries         int            `json:"entries"`
+	Dropped         map[string]int `json:"dropped"`
+	DurationSeconds float64        `json:"duration_seconds"`
+}
+
+type report struct {
+	Patterns []patternRecord `json:"patterns"`
+	Levels   []levelRecord   `json:"levels"`
+	Stats    statsRecord     `json:"stats"`
+}
+
+// newReport converts the ordered counters, the percentage of a pattern is its share among the sampled patterns as in the text output
+func newReport(counters []logparser.LogCounter, stats logparser.Stats, duration time.Duration) report {
+	r := report{
+		Patterns: []patternRecord{},
+		Levels:   []levelRecord{},
+		Stats:    statsRecord{Entries: stats.Entries, Dropped: map[string]int{}, DurationSeconds: math.Round(duration.Seconds()*1000) / 1000},
+	}
+	total := 0
+	byLevel := map[logparser.Level]int{}
+	for _, c := range counters {
+		r.Stats.Messages += c.Messages
+		byLevel[c.Level] += c.Messages
+		if c.Sample != "" {
+			total += c.Messages
+		}
+	}
+	for _, c := range counters {
+		if c.Sample == "" {
+			continue
+		}
+		r.Patterns = append(r.Patterns, patternRecord{
+			Level:      c.Level.String(),
+			Hash:       c.Hash,
+			Pattern:    c.Pattern,
+			Sample:     c.Sample,
+			Count:      c.Messages,
+			Percentage: math.Round(float64(c.Messages*10000)/float64(total)) / 100,
+			Sources:    c.Sources,
+		})
+	}
+	levels := make([]logparser.Level, 0, len(byLevel))
+	for l := range byLevel {
+		levels = append(levels, l)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].SeverityNumber() > levels[j].SeverityNumber() })
+	for _, l := range levels {
+		r.Levels = append(r.Levels, levelRecord{Level: l.String(), Count: byLevel[l]})
+	}
+	for reason, c := range stats.Dropped {
+		r.Stats.Dropped[string(reason)] = c
+	}
+	return r
+}
+
+// write prints the counters in the format, text is printed for the terminal
+func write(w io.Writer, format string, counters []logparser.LogCounter, stats logparser.Stats, screenWidth int, duration time.Duration) error {
+	if format == formatText {
+		output(counters, stats, screenWidth, duration)
+		return nil
+	}
+	r := newReport(counters, stats, duration)
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case formatNDJSON:
+		return writeNDJSON(w, r)
+	case formatCSV:
+		return writeCSV(w, r)
+	case formatMarkdown:
+		return writeMarkdown(w, r)
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+// writeNDJSON prints a record per line, the type field is one of pattern, level or stats
+func writeNDJSON(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, p := range r.Patterns {
+		if err := enc.Encode(struct {
+			Type string `json:"type"`
+			patternRecord
+		}{"pattern", p}); err != nil {
+			return err
+		}
+	}
+	for _, l := range r.Levels {
+		if err := enc.Encode(struct {
+			Type string `json:"type"`
+			levelRecord
+		}{"level", l}); err != nil {
+			return err
+		}
+	}
+	return enc.Encode(struct {
+		Type string `json:"type"`
+		statsRecord
+	}{"stats", r.Stats})
+}
+
+// writeCSV prints a row per pattern and level, stats rows have the name in the pattern column and the value in the count column
+func writeCSV(w io.Writer, r report) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"type", "level", "hash", "pattern", "sample", "count", "percentage"})
+	for _, p := range r.Patterns {
+		_ = cw.Write([]string{"pattern", p.Level, p.Hash, p.Pattern, p.Sample, strconv.Itoa(p.Count), strconv.FormatFloat(p.Percentage, 'f', -1, 64)})
+	}
+	for _, l := range r.Levels {
+		_ = cw.Write([]string{"level", l.Level, "", "", "", strconv.Itoa(l.Count), ""})
+	}
+	stat := func(name, value string) {
+		_ = cw.Write([]string{"stats", "", "", name, "", value, ""})
+	}
+	stat("messages", strconv.Itoa(r.Stats.Messages))
+	stat("entries", strconv.Itoa(r.Stats.Entries))
+	for _, reason := range sortedKeys(r.Stats.Dropped) {
+		stat("dropped: "+reason, strconv.Itoa(r.Stats.Dropped[reason]))
+	}
+	stat("duration_seconds", strconv.FormatFloat(r.Stats.DurationSeconds, 'f', -1, 64))
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeMarkdown(w io.Writer, r report) error {
+	var b strings.Builder
+	b.WriteString("## Patterns\n\n")
+	b.WriteString("| Level | Count | % | Hash | Pattern | Sample |\n")
+	b.WriteString("|---|---:|---:|---|---|---|\n")
+	for _, p := range r.Patterns {
+		fmt.Fprintf(&b, "| %s | %d | %s | `%s` | %s | %s |\n",
+			p.Level, p.Count, strconv.FormatFloat(p.Percentage, 'f', -1, 64), p.Hash, markdownCell(p.Pattern), markdownCell(p.Sample))
+	}
+	b.WriteString("\n## Levels\n\n")
+	b.WriteString("| Level | Messages |\n")
+	b.WriteString("|---|---:|\n")
+	for _, l := range r.Levels {
+		fmt.Fprintf(&b, "| %s | %d |\n", l.Level, l.Count)
+	}
+	b.WriteString("\n## Stats\n\n")
+	fmt.Fprintf(&b, "- messages: %d\n", r.Stats.Messages)
+	fmt.Fprintf(&b, "- entries: %d\n", r.Stats.Entries)
+	for _, reason := range sortedKeys(r.Stats.Dropped) {
+		fmt.Fprintf(&b, "- dropped (%s): %d\n", reason, r.Stats.Dropped[reason])
+	}
+	fmt.Fprintf(&b, "- duration: %ss\n", strconv.FormatFloat(r.Stats.DurationSeconds, 'f', -1, 64))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes the text for a table cell, the lines of multiline messages are joined with <br>
+func markdownCell(s string) string {
+	s = strings.NewReplacer("\\", "\\\\", "|", "\\|", "<", "&lt;", ">", "&gt;", "\r", "").Replace(s)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeOrExit(format string, counters []logparser.LogCounter, stats logparser.Stats, screenWidth int, duration time.Duration) {
+	if err := write(os.Stdout, format, counters, stats, screenWidth, duration); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	logparser "github.com/prs-io/plexus-logparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCounters() ([]logparser.LogCounter, logparser.Stats) {
+	counters := []logparser.LogCounter{
+		{Level: logparser.LevelError, Hash: "e1", Pattern: `failed to parse "a,b"`, Sample: "failed to parse \"a,b\"\n\tat main.go:12", Messages: 3},
+		{Level: logparser.LevelInfo, Hash: "i1", Pattern: "columns a | b", Sample: "columns a | b <none>", Messages: 1},
+		{Level: logparser.LevelInfo, Messages: 4},
+	}
+	stats := logparser.Stats{Entries: 9, Dropped: map[logparser.DropReason]int{logparser.DropReasonTruncated: 2, logparser.DropReasonInvalidUtf8: 1}}
+	return counters, stats
+}
+
+func TestNewReport(t *testing.T) {
+	counters, stats := testCounters()
+	r := newReport(counters, stats, 1500*time.Millisecond)
+	require.Len(t, r.Patterns, 2)
+	// the percentage is the share among the sampled patterns
+	assert.Equal(t, 75.0, r.Patterns[0].Percentage)
+	assert.Equal(t, 25.0, r.Patterns[1].Percentage)
+	assert.Equal(t, []levelRecord{{Level: "error", Count: 3}, {Level: "info", Count: 5}}, r.Levels)
+	assert.Equal(t, 8, r.Stats.Messages)
+	assert.Equal(t, 1.5, r.Stats.DurationSeconds)
+}
+
+func TestWriteFormats(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		expected string
+	}{
+		{format: formatJSON, expected: `{
+  "patterns": [
+    {
+      "level": "error",
+      "hash": "e1",
+      "pattern": "failed to parse \"a,b\"",
+      "sample": "failed to parse \"a,b\"\n\tat main.go:12",
+      "count": 3,
+      "percentage": 75
+    },
+    {
+      "level": "info",
+      "hash": "i1",
+      "pattern": "columns a | b",
+      "sample": "columns a | b <none>",
+      "count": 1,
+      "percentage": 25
+    }
+  ],
+  "levels": [
+    {
+      "level": "error",
+      "count": 3
+    },
+    {
+      "level": "info",
+      "count": 5
+    }
+  ],
+  "stats": {
+    "messages": 8,
+    "entries": 9,
+    "dropped": {
+      "invalid UTF-8": 1,
+      "truncated": 2
+    },
+    "duration_seconds": 1.5
+  }
+}
+`},
+		{format: formatNDJSON, expected: `{"type":"pattern","level":"error","hash":"e1","pattern":"failed to parse \"a,b\"","sample":"failed to parse \"a,b\"\n\tat main.go:12","count":3,"percentage":75}
+{"type":"pattern","level":"info","hash":"i1","pattern":"columns a | b","sample":"columns a | b <none>","count":1,"percentage":25}
+{"type":"level","level":"error","count":3}
+{"type":"level","level":"info","count":5}
+{"type":"stats","messages":8,"entries":9,"dropped":{"invalid UTF-8":1,"truncated":2},"duration_seconds":1.5}
+`},
+		{format: formatCSV, expected: `type,level,hash,pattern,sample,count,percentage
+pattern,error,e1,"failed to parse ""a,b""","failed to parse ""a,b""
+	at main.go:12",3,75
+pattern,info,i1,columns a | b,columns a | b <none>,1,25
+level,error,,,,3,
+level,info,,,,5,
+stats,,,messages,,8,
+stats,,,entries,,9,
+stats,,,dropped: invalid UTF-8,,1,
+stats,,,dropped: truncated,,2,
+stats,,,duration_seconds,,1.5,
+`},
+		{format: formatMarkdown, expected: "## Patterns\n\n" +
+			"| Level | Count | % | Hash | Pattern | Sample |\n" +
+			"|---|---:|---:|---|---|---|\n" +
+			"| error | 3 | 75 | `e1` | failed to parse \"a,b\" | failed to parse \"a,b\"<br>\tat main.go:12 |\n" +
+			"| info | 1 | 25 | `i1` | columns a \\| b | columns a \\| b &lt;none&gt; |\n" +
+			"\n## Levels\n\n" +
+			"| Level | Messages |\n" +
+			"|---|---:|\n" +
+			"| error | 3 |\n" +
+			"| info | 5 |\n" +
+			"\n## Stats\n\n" +
+			"- messages: 8\n" +
+			"- entries: 9\n" +
+			"- dropped (invalid UTF-8): 1\n" +
+			"- dropped (truncated): 2\n" +
+			"- duration: 1.5s\n"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			counters, stats := testCounters()
+			b := &bytes.Buffer{}
+			require.NoError(t, write(b, tc.format, counters, stats, 0, 1500*time.Millisecond))
+			assert.Equal(t, tc.expected, b.String())
+		})
+	}
+	assert.EqualError(t, write(&bytes.Buffer{}, "xml", nil, logparser.Stats{}, 0, 0), "unknown format: xml")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	logparser "github.com/prs-io/plexus-logparser"
+)
+
+const (
+	stdinSource  = "stdin"
+	maxOpenFiles = 16
+	// enough for the magic bytes of all the supported formats, tar has them at the offset 257
+	headSize = 512
+)
+
+// zip archives that are not regular files (e.g. stdin or a member of an archive) are read into memory
+var maxInMemoryZipSize = 256 << 20
+
+// expandPaths resolves globs and walks directories recursively, e.g. /var/log/pods or '/var/log/pods/*/*/*.log'
+func expandPaths(args []string) ([]string, error) {
+	var res []string
+	seen := map[string]bool{}
+	for _, arg := range args {
+		if arg == "-" {
+			res = append(res, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no such file or directory: %s", arg)
+		}
+		for _, m := range matches {
+			err = filepath.WalkDir(m, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() && !seen[p] {
+					seen[p] = true
+					res = append(res, p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// readAll reads the files concurrently (stdin if there are no files), each file is a separate source
+func readAll(paths []string, ch chan<- logparser.LogEntry) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	sem := make(chan struct{}, maxOpenFiles)
+	wg := sync.WaitGroup{}
+	for _, p := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := readPath(p, ch); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+}
+
+func readPath(path string, ch chan<- logparser.LogEntry) error {
+	if path == "-" {
+		return read(os.Stdin, stdinSource, ch)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return read(f, path, ch)
+}
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+	zipMagic   = []byte("PK\x03\x04")
+	tarMagic   = []byte("ustar")
+)
+
+const tarMagicOffset = 257
+
+// read detects compression and archives by magic bytes, compressed data is decompressed transparently,
+// members of tar and zip archives are read as separate sources named archive:member
+func read(r io.Reader, source string, ch chan<- logparser.LogEntry) error {
+	reader := bufio.NewReader(r)
+	// a stream shorter than headSize is checked once it ends
+	head, err := reader.Peek(headSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", source, err)
+		}
+		defer zr.Close()
+		return read(zr, source, ch)
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", source, err)
+		}
+		defer zr.Close()
+		return read(zr, source, ch)
+	case bytes.HasPrefix(head, bzip2Magic):
+		return read(bzip2.NewReader(reader), source, ch)
+	case bytes.HasPrefix(head, zipMagic):
+		return readZip(r, reader, source, ch)
+	case len(head) >= tarMagicOffset+len(tarMagic) && bytes.Equal(head[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return readTar(reader, source, ch)
+	case bytes.IndexByte(head, 0) >= 0:
+		return fmt.Errorf("skipping binary file: %s", source)
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			ch <- logparser.LogEntry{Timestamp: time.Now(), Content: strings.TrimSuffix(line, "\n"), Level: logparser.LevelUnknown, Source: source}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read %s: %w", source, err)
+		}
+	}
+}
+
+func readTar(r io.Reader, source string, ch chan<- logparser.LogEntry) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", source, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = read(tr, source+":"+h.Name, ch); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// readZip needs random access, so the archive is read into memory unless it is a regular file
+func readZip(r io.Reader, buffered io.Reader, source string, ch chan<- logparser.LogEntry) error {
+	var ra io.ReaderAt
+	var size int64
+	if f, ok := r.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			ra, size = f, fi.Size()
+		}
+	}
+	if ra == nil {
+		data, err := io.ReadAll(io.LimitReader(buffered, int64(maxInMemoryZipSize)+1))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", source, err)
+		}
+		if len(data) > maxInMemoryZipSize {
+			return fmt.Errorf("skipping zip archive larger than %d MiB, only regular zip files can be larger: %s", maxInMemoryZipSize>>20, source)
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		if err = readZipFile(zf, source+":"+zf.Name, ch); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	return nil
+}
+
+func readZipFile(zf *zip.File, source string, ch chan<- logparser.LogEntry) error {
+	f, err := zf.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	defer f.Close()
+	return read(f, source, ch)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	logparser "github.com/prs-io/plexus-logparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.log":                         "",
+		"app.log.1":                       "",
+		"pods/default_web/app/0.log":      "",
+		"pods/default_web/app/1.log":      "",
+		"pods/kube-system_dns/dns/0.log":  "",
+		"pods/kube-system_dns/dns/README": "",
+	})
+	p := func(name string) string { return filepath.Join(dir, name) }
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		res  []string
+		err  string
+	}{
+		{name: "file", args: []string{p("app.log")}, res: []string{p("app.log")}},
+		{name: "stdin", args: []string{"-", p("app.log")}, res: []string{"-", p("app.log")}},
+		{name: "glob", args: []string{p("app.log*")}, res: []string{p("app.log"), p("app.log.1")}},
+		{name: "glob of directories", args: []string{p("pods/*/*/*.log")}, res: []string{
+			p("pods/default_web/app/0.log"), p("pods/default_web/app/1.log"), p("pods/kube-system_dns/dns/0.log"),
+		}},
+		{name: "directory", args: []string{p("pods/kube-system_dns")}, res: []string{p("pods/kube-system_dns/dns/0.log"), p("pods/kube-system_dns/dns/README")}},
+		{name: "duplicates", args: []string{p("pods/default_web"), p("pods/*/app/0.log")}, res: []string{p("pods/default_web/app/0.log"), p("pods/default_web/app/1.log")}},
+		{name: "no match", args: []string{p("app.log"), p("*.gz")}, err: "no such file or directory: " + p("*.gz")},
+		{name: "missing file", args: []string{p("missing.log")}, err: "no such file or directory: " + p("missing.log")},
+		{name: "invalid pattern", args: []string{p("[")}, err: "invalid pattern"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := expandPaths(tc.args)
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.res, res)
+		})
+	}
+}
+
+type readLine struct {
+	source  string
+	content string
+}
+
+func readLines(t *testing.T, data []byte, source string) ([]readLine, error) {
+	t.Helper()
+	ch := make(chan logparser.LogEntry, 100)
+	err := read(strings.NewReader(string(data)), source, ch)
+	close(ch)
+	var res []readLine
+	for e := range ch {
+		res = append(res, readLine{source: e.Source, content: e.Content})
+	}
+	return res, err
+}
+
+func TestRead(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		lines []readLine
+		err   string
+	}{
+		{name: "empty", data: nil},
+		{name: "lines", data: []byte("first\n\nlast"), lines: []readLine{{"app.log", "first"}, {"app.log", ""}, {"app.log", "last"}}},
+		{name: "binary", data: []byte("\x7fELF\x02\x01\x01\x00\x00"), err: "skipping binary file: app.log"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, err := readLines(t, tc.data, "app.log")
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.lines, lines)
+		})
+	}
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	w := gzip.NewWriter(b)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func zstdData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer w.Close()
+	return w.EncodeAll(data, nil)
+}
+
+func tarData(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	w := tar.NewWriter(b)
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for _, name := range sortedNames(files) {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))}))
+		_, err := w.Write(files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func zipData(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	w := zip.NewWriter(b)
+	_, err := w.Create("logs/")
+	require.NoError(t, err)
+	for _, name := range sortedNames(files) {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write(files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReadCompressed(t *testing.T) {
+	lines := []byte("first\nsecond\n")
+	bz2, err := os.ReadFile("testdata/app.log.bz2")
+	require.NoError(t, err)
+	members := map[string][]byte{
+		"logs/app.log":    lines,
+		"logs/db.log.gz":  gzipData(t, []byte("db\n")),
+		"logs/core":       {0x7f, 'E', 'L', 'F', 0},
+		"logs/nested.tar": tarData(t, map[string][]byte{"inner.log": []byte("inner\n")}),
+	}
+
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		lines []readLine
+	}{
+		{name: "gzip", data: gzipData(t, lines), lines: []readLine{{"app.log", "first"}, {"app.log", "second"}}},
+		{name: "zstd", data: zstdData(t, lines), lines: []readLine{{"app.log", "first"}, {"app.log", "second"}}},
+		{name: "bzip2", data: bz2, lines: []readLine{{"app.log", "first"}, {"app.log", "second"}}},
+		{name: "gzip of zstd", data: gzipData(t, zstdData(t, lines)), lines: []readLine{{"app.log", "first"}, {"app.log", "second"}}},
+		{name: "tar", data: tarData(t, members), lines: []readLine{
+			{"app.log:logs/app.log", "first"}, {"app.log:logs/app.log", "second"},
+			{"app.log:logs/db.log.gz", "db"},
+			{"app.log:logs/nested.tar:inner.log", "inner"},
+		}},
+		{name: "tar.gz", data: gzipData(t, tarData(t, map[string][]byte{"app.log": lines})), lines: []readLine{{"app.log:app.log", "first"}, {"app.log:app.log", "second"}}},
+		{name: "zip", data: zipData(t, members), lines: []readLine{
+			{"app.log:logs/app.log", "first"}, {"app.log:logs/app.log", "second"},
+			{"app.log:logs/db.log.gz", "db"},
+			{"app.log:logs/nested.tar:inner.log", "inner"},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := readLines(t, tc.data, "app.log")
+			require.NoError(t, err)
+			assert.Equal(t, tc.lines, res)
+		})
+	}
+}
+
+func TestReadZipSizeLimit(t *testing.T) {
+	data := zipData(t, map[string][]byte{"app.log": []byte("first\n")})
+	defer func(size int) { maxInMemoryZipSize = size }(maxInMemoryZipSize)
+	maxInMemoryZipSize = len(data) - 1
+
+	_, err := readLines(t, data, "app.zip")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "skipping zip archive larger than")
+
+	// a regular file is read in place
+	path := filepath.Join(t.TempDir(), "app.zip")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	ch := make(chan logparser.LogEntry, 10)
+	require.NoError(t, readPath(path, ch))
+	close(ch)
+	var res []readLine
+	for e := range ch {
+		res = append(res, readLine{source: e.Source, content: e.Content})
+	}
+	assert.Equal(t, []readLine{{path + ":app.log", "first"}}, res)
+}
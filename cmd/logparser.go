@@ -1,15 +1,15 @@
 package main
 
 import (
-	"bufio"
-	"errors"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	logparser "github.com/prs-io/plexus-logparser"
@@ -17,32 +17,192 @@ import (
 
 func main() {
 	screenWidth := flag.Int("w", 120, "terminal width")
-	maxLinesPerMessage := flag.Int("l", 100, "max lines per message")
+	maxLinesPerMessage := flag.Int("l", 100, "max lines per message, the rest of the lines are dropped")
+	charset := flag.String("charset", "", "charset of lines that are not valid UTF-8, e.g. ISO-8859-1, windows-1252 or Shift_JIS (by default, such lines are dropped)")
+	invalidUTF8 := flag.String("invalid-utf8", "drop", "what to do with lines that are not valid UTF-8 if -charset is not set: drop or replace (the invalid bytes with U+FFFD)")
+	followMode := flag.Bool("f", false, "follow the files (or stdin) and redraw the top patterns until interrupted")
+	redrawInterval := flag.Duration("i", 2*time.Second, "redraw interval in the follow mode")
+	top := flag.Int("top", 20, "number of patterns shown in the follow mode")
+	format := flag.String("format", formatText, "output format (see -decoder for the input format): "+strings.Join(formats, ", "))
+	decoderFormat := flag.String("decoder", logparser.FormatPlain, "input format (-format is the output format): "+strings.Join(decoderFormats, ", "))
+	levelPolicy := flag.String("level-policy", "field,content", "comma-separated sources of the message level in the order of precedence: field (set by the decoder), content (guessed) and stream (stderr is a warning)")
+	multilineTimeout := flag.Duration("multiline-timeout", time.Second, "time to wait for the next line of a multiline message")
+	baselineFile := flag.String("baseline", "", "baseline file to compare with, the exit code is 1 if new warning, error or critical patterns appear")
+	baselineMaxGrowth := flag.Float64("baseline-max-growth", 0, "also fail if a pattern's count exceeds its baseline count multiplied by this factor (0 disables the check)")
+	baselineWriteFile := flag.String("baseline-write", "", "write the patterns of this run to a baseline file")
+	multilineRulesFile := flag.String("multiline-rules", "", "JSON file with multiline rules replacing the built-in heuristics, the rules are selected by file path or stdin")
 
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file|dir|glob ...]\n\nReads stdin if no files are given or a file is -.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	reader := bufio.NewReader(os.Stdin)
-	ch := make(chan logparser.LogEntry)
-	parser := logparser.NewParser(ch, nil, nil, time.Second)
-	t := time.Now()
-	for {
-		line, err := reader.ReadString('\n')
+	if !validFormat(*format) {
+		fmt.Printf("unknown format: %s\n", *format)
+		os.Exit(1)
+	}
+
+	paths, err := expandPaths(flag.Args())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *followMode && (*baselineFile != "" || *baselineWriteFile != "") {
+		fmt.Println("baseline is not supported in the follow mode")
+		os.Exit(1)
+	}
+	var base *baseline
+	if *baselineFile != "" {
+		if base, err = readBaseline(*baselineFile); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	decoder, err := newDecoder(*decoderFormat)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	policy, err := logparser.ParseLevelPolicy(*levelPolicy)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	opts := []logparser.Option{logparser.WithMaxLinesPerMessage(*maxLinesPerMessage), logparser.WithLevelPolicy(policy...)}
+	switch {
+	case *invalidUTF8 != "drop" && *invalidUTF8 != "replace":
+		fmt.Printf("unknown invalid UTF-8 policy: %s\n", *invalidUTF8)
+		os.Exit(1)
+	case *charset != "" && *invalidUTF8 == "replace":
+		fmt.Println("-charset and -invalid-utf8=replace are mutually exclusive")
+		os.Exit(1)
+	case *charset != "":
+		enc, err := logparser.Charset(*charset)
 		if err != nil {
-			if !errors.Is(err, io.EOF) {
-				fmt.Println(err)
-			}
-			break
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		ch <- logparser.LogEntry{Timestamp: time.Now(), Content: strings.TrimSuffix(line, "\n"), Level: logparser.LevelUnknown}
+		opts = append(opts, logparser.WithUTF8Policy(logparser.UTF8PolicyTranscode, enc))
+	case *invalidUTF8 == "replace":
+		opts = append(opts, logparser.WithUTF8Policy(logparser.UTF8PolicyReplace, nil))
 	}
-	d := time.Since(t)
+	if *multilineRulesFile != "" {
+		data, err := os.ReadFile(*multilineRulesFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		rules, err := logparser.ParseMultilineRules(data)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		opts = append(opts, logparser.WithMultilineRules(rules))
+	}
+
+	ch := make(chan logparser.LogEntry)
+	parser := logparser.NewParser(ch, decoder, nil, *multilineTimeout, opts...)
 	defer parser.Stop()
 
+	if *followMode {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		follow(ctx, paths, ch, parser, *format, *redrawInterval, *top, *screenWidth)
+		return
+	}
+
+	t := time.Now()
+	readAll(paths, ch)
+	close(ch)
+	<-parser.Done()
+	d := time.Since(t)
+
 	counters := parser.GetCounters()
 
 	order(counters)
 
-	output(counters, *screenWidth, *maxLinesPerMessage, d)
+	writeOrExit(*format, counters, parser.GetStats(), *screenWidth, d)
+
+	var violations []baselineViolation
+	if base != nil {
+		violations = base.compare(counters, *baselineMaxGrowth)
+	}
+	if *baselineWriteFile != "" {
+		if err = writeBaseline(*baselineWriteFile, counters); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if len(violations) > 0 {
+		// stderr keeps the machine-readable output intact
+		reportViolations(os.Stderr, violations, *screenWidth)
+		os.Exit(1)
+	}
+}
+
+const (
+	formatAuto            = "auto"
+	autoDecoderSampleSize = 100
+)
+
+var decoderFormats = []string{
+	logparser.FormatPlain, logparser.FormatDocker, logparser.FormatCri, logparser.FormatJson, logparser.FormatLogfmt, logparser.FormatSyslog, formatAuto,
+}
+
+// newDecoder returns the decoder of the input format, lines are parsed as is in the plain format.
+// Each source gets its own decoder, so the auto-detection runs per file.
+func newDecoder(format string) (logparser.Decoder, error) {
+	var factory func() logparser.Decoder
+	switch format {
+	case logparser.FormatPlain:
+		return nil, nil
+	case logparser.FormatDocker:
+		return logparser.DockerJsonDecoder{}, nil
+	case logparser.FormatCri:
+		return logparser.CriDecoder{}, nil
+	case logparser.FormatJson:
+		return logparser.JsonDecoder{}, nil
+	case logparser.FormatLogfmt:
+		return logparser.LogfmtDecoder{}, nil
+	case logparser.FormatSyslog:
+		return logparser.SyslogDecoder{}, nil
+	case formatAuto:
+		factory = func() logparser.Decoder { return logparser.NewAutoDecoder(autoDecoderSampleSize) }
+	default:
+		return nil, fmt.Errorf("unknown decoder: %s", format)
+	}
+	return &sourceDecoder{factory: factory, decoders: map[string]logparser.Decoder{}}, nil
+}
+
+// sourceDecoder creates a decoder per LogEntry.Source for stateful decoders such as AutoDecoder.
+// The parser decodes the entries in a single goroutine.
+type sourceDecoder struct {
+	factory  func() logparser.Decoder
+	decoders map[string]logparser.Decoder
+}
+
+func (d *sourceDecoder) Decode(src string) (string, error) {
+	entry, err := d.DecodeEntry(logparser.LogEntry{Content: src})
+	return entry.Content, err
+}
+
+func (d *sourceDecoder) DecodeEntry(entry logparser.LogEntry) (logparser.LogEntry, error) {
+	decoder := d.decoders[entry.Source]
+	if decoder == nil {
+		decoder = d.factory()
+		d.decoders[entry.Source] = decoder
+	}
+	if ed, ok := decoder.(logparser.EntryDecoder); ok {
+		return ed.DecodeEntry(entry)
+	}
+	var err error
+	entry.Content, err = decoder.Decode(entry.Content)
+	return entry, err
 }
 
 func order(counters []logparser.LogCounter) {
@@ -51,11 +211,11 @@ func order(counters []logparser.LogCounter) {
 		if ci.Level == cj.Level {
 			return ci.Messages > cj.Messages
 		}
-		return ci.Level < cj.Level
+		return ci.Level.SeverityNumber() > cj.Level.SeverityNumber()
 	})
 }
 
-func output(counters []logparser.LogCounter, screenWidth, maxLinesPerMessage int, duration time.Duration) {
+func output(counters []logparser.LogCounter, stats logparser.Stats, screenWidth int, duration time.Duration) {
 	grandTotal, total, max := 0, 0, 0
 	for _, c := range counters {
 		grandTotal += c.Messages
@@ -78,15 +238,17 @@ func output(counters []logparser.LogCounter, screenWidth, maxLinesPerMessage int
 		bar := strings.Repeat("▇", w+1) + strings.Repeat(" ", barWidth-w)
 		prefix := colorize(c.Level, "%s "+messagesNumFmt+" (%2d%%) ", bar, c.Messages, int(float64(c.Messages*100)/float64(total)))
 		sample := ""
-		for i, line := range strings.Split(c.Sample, "\n") {
+		for _, line := range strings.Split(c.Sample, "\n") {
 			if len(line) > lineWidth {
 				line = line[:lineWidth] + "..."
 			}
 			sample += line + "\n" + strings.Repeat(" ", len(prefix))
-			if i > maxLinesPerMessage {
-				sample += "...\n"
-				break
-			}
+		}
+		if c.Truncated {
+			sample += fmt.Sprintf("... (truncated, %d lines, %d bytes in total)\n", c.Lines, c.Size)
+		}
+		if len(c.Sources) > 1 || len(c.Sources) == 1 && c.Sources[stdinSource] == 0 {
+			sample += sources(c.Sources, lineWidth) + "\n"
 		}
 		sample = strings.TrimRight(sample, "\n ")
 		fmt.Printf("%s%s\n", prefix, sample)
@@ -102,16 +264,69 @@ func output(counters []logparser.LogCounter, screenWidth, maxLinesPerMessage int
 		fmt.Printf("  %s: %d\n", l, c)
 	}
 	fmt.Println()
+
+	dropped := 0
+	for _, c := range stats.Dropped {
+		dropped += c
+	}
+	if dropped == 0 {
+		return
+	}
+	fmt.Printf("%d of %d lines dropped or truncated:\n", dropped, stats.Entries)
+	reasons := make([]logparser.DropReason, 0, len(stats.Dropped))
+	for reason := range stats.Dropped {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+	for _, reason := range reasons {
+		fmt.Printf("  %s: %d\n", reason, stats.Dropped[reason])
+		// the samples of the sources are merged in no particular order
+		samples := append([]string(nil), stats.Samples[reason]...)
+		sort.Strings(samples)
+		for _, sample := range samples {
+			if len(sample) > screenWidth {
+				sample = sample[:screenWidth] + "..."
+			}
+			fmt.Printf("    %q\n", sample)
+		}
+	}
+	fmt.Println()
+}
+
+// sources lists the files the messages appeared in, the most frequent first
+func sources(counts map[string]int, width int) string {
+	names := make([]string, 0, len(counts))
+	for s := range counts {
+		names = append(names, s)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] == counts[names[j]] {
+			return names[i] < names[j]
+		}
+		return counts[names[i]] > counts[names[j]]
+	})
+	res := "in"
+	for i, s := range names {
+		item := fmt.Sprintf(" %s (%d)", s, counts[s])
+		if i > 0 && len(res)+len(item) > width {
+			return res + fmt.Sprintf(" and %d more", len(names)-i)
+		}
+		if i > 0 {
+			res += ","
+		}
+		res += item
+	}
+	return res
 }
 
 func colorize(level logparser.Level, format string, a ...interface{}) string {
 	c := "\033[37m" // grey
 	switch level {
-	case logparser.LevelCritical, logparser.LevelError:
+	case logparser.LevelCritical, logparser.LevelFatal, logparser.LevelError:
 		c = "\033[31m" // red
 	case logparser.LevelWarning:
 		c = "\033[33m" // yellow
-	case logparser.LevelInfo:
+	case logparser.LevelNotice, logparser.LevelInfo:
 		c = "\033[32m" // green
 	}
 	return fmt.Sprintf(c+format+"\033[0m", a...)
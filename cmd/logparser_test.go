@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	logparser "github.com/prs-io/plexus-logparser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceDecoder(t *testing.T) {
+	decoder, err := newDecoder(formatAuto)
+	require.NoError(t, err)
+	d := decoder.(logparser.EntryDecoder)
+
+	// the formats are detected per source
+	for i := 0; i < autoDecoderSampleSize; i++ {
+		entry, err := d.DecodeEntry(logparser.LogEntry{Content: `{"log":"ERROR boom\n","stream":"stderr","time":"2024-01-01T00:00:00Z"}`, Source: "docker.log"})
+		require.NoError(t, err)
+		assert.Equal(t, "ERROR boom\n", entry.Content)
+		entry, err = d.DecodeEntry(logparser.LogEntry{Content: `level=error msg="db is down"`, Source: "app.log"})
+		require.NoError(t, err)
+		assert.Equal(t, "db is down", entry.Content)
+	}
+
+	decoder, err = newDecoder(logparser.FormatPlain)
+	require.NoError(t, err)
+	assert.Nil(t, decoder)
+	_, err = newDecoder("xml")
+	assert.Error(t, err)
+}
@@ -3,17 +3,34 @@ package logparser
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
 type DockerLogJson struct {
-	Log string
+	Log    string
+	Stream string
 }
 
 type Decoder interface {
 	Decode(string) (string, error)
 }
 
+// EntryDecoder is implemented by decoders that can extract the timestamp, level and
+// additional fields from a structured entry in addition to its content.
+type EntryDecoder interface {
+	DecodeEntry(LogEntry) (LogEntry, error)
+}
+
+func decode(decoder Decoder, entry LogEntry) (LogEntry, error) {
+	if d, ok := decoder.(EntryDecoder); ok {
+		return d.DecodeEntry(entry)
+	}
+	var err error
+	entry.Content, err = decoder.Decode(entry.Content)
+	return entry, err
+}
+
 type DockerJsonDecoder struct{}
 
 func (d DockerJsonDecoder) Decode(src string) (string, error) {
@@ -24,6 +41,16 @@ func (d DockerJsonDecoder) Decode(src string) (string, error) {
 	return obj.Log, nil
 }
 
+func (d DockerJsonDecoder) DecodeEntry(entry LogEntry) (LogEntry, error) {
+	obj := DockerLogJson{}
+	if err := json.Unmarshal([]byte(entry.Content), &obj); err != nil {
+		return entry, fmt.Errorf(`failed to unmarshal docker log entry "%s": %s`, entry.Content, err)
+	}
+	entry.Content = obj.Log
+	entry.Stream = obj.Stream
+	return entry, nil
+}
+
 type CriDecoder struct{}
 
 func (d CriDecoder) Decode(src string) (string, error) {
@@ -39,3 +66,93 @@ func (d CriDecoder) Decode(src string) (string, error) {
 	}
 	return src[i+1:], nil
 }
+
+// 2016-10-06T00:17:09.669794202Z stderr F message
+func (d CriDecoder) DecodeEntry(entry LogEntry) (LogEntry, error) {
+	parts := strings.SplitN(entry.Content, " ", 4)
+	if len(parts) < 4 {
+		return entry, fmt.Errorf("unexpected entry format: %s", entry.Content)
+	}
+	entry.Content = parts[3]
+	if parts[1] == StreamStdout || parts[1] == StreamStderr {
+		entry.Stream = parts[1]
+	}
+	return entry, nil
+}
+
+var (
+	jsonMessageKeys   = []string{"msg", "message"}
+	jsonLevelKeys     = []string{"level", "lvl", "severity"}
+	jsonTimestampKeys = []string{"time", "ts", "timestamp", "@timestamp"}
+)
+
+type JsonDecoder struct{}
+
+func (d JsonDecoder) Decode(src string) (string, error) {
+	entry, err := d.DecodeEntry(LogEntry{Content: src})
+	if err != nil {
+		return "", err
+	}
+	return entry.Content, nil
+}
+
+func (d JsonDecoder) DecodeEntry(entry LogEntry) (LogEntry, error) {
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(entry.Content), &obj); err != nil {
+		return entry, fmt.Errorf(`failed to unmarshal json log entry "%s": %s`, entry.Content, err)
+	}
+	fields := make(map[string]string, len(obj))
+	for k, v := range obj {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			s = string(v)
+		}
+		fields[k] = s
+	}
+	if k := firstKey(fields, jsonMessageKeys); k != "" {
+		entry.Content = fields[k]
+		delete(fields, k)
+	}
+	if k := firstKey(fields, jsonLevelKeys); k != "" {
+		if l := jsonLevel(fields[k]); l != LevelUnknown {
+			entry.Level = l
+			delete(fields, k)
+		}
+	}
+	if k := firstKey(fields, jsonTimestampKeys); k != "" {
+		if ts, ok := parseTimestamp(fields[k]); ok {
+			entry.Timestamp = ts
+			delete(fields, k)
+		}
+	}
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry, nil
+}
+
+// bunyan and pino use numeric levels: 10 trace, 20 debug, 30 info, 40 warn, 50 error, 60 fatal
+func jsonLevel(s string) Level {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		if l := LevelFromString(s); l != LevelUnknown {
+			return l
+		}
+		return GuessLevel(s)
+	}
+	switch {
+	case n >= 60:
+		return LevelFatal
+	case n >= 50:
+		return LevelError
+	case n >= 40:
+		return LevelWarning
+	case n >= 30:
+		return LevelInfo
+	case n >= 20:
+		return LevelDebug
+	case n > 0:
+		return LevelTrace
+	}
+	return LevelUnknown
+}
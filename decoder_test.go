@@ -0,0 +1,51 @@
+package logparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJsonDecoder(t *testing.T) {
+	d := JsonDecoder{}
+
+	entry, err := d.DecodeEntry(LogEntry{Content: `{"time":"2024-01-15T10:20:30Z","level":"error","msg":"request failed","status":502,"ctx":{"id":1}}`})
+	require.NoError(t, err)
+	assert.Equal(t, "request failed", entry.Content)
+	assert.Equal(t, LevelError, entry.Level)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 20, 30, 0, time.UTC), entry.Timestamp)
+	assert.Equal(t, map[string]string{"status": "502", "ctx": `{"id":1}`}, entry.Fields)
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `{"level":50,"time":1705314030000,"pid":1,"msg":"boom"}`})
+	require.NoError(t, err)
+	assert.Equal(t, "boom", entry.Content)
+	assert.Equal(t, LevelError, entry.Level)
+	assert.Equal(t, time.Unix(1705314030, 0), entry.Timestamp)
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `{"a":"b"}`})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":"b"}`, entry.Content)
+	assert.Equal(t, map[string]string{"a": "b"}, entry.Fields)
+
+	_, err = d.Decode(`["not", "an", "object"]`)
+	assert.Error(t, err)
+	_, err = d.Decode(`plain text`)
+	assert.Error(t, err)
+}
+
+func TestStreamDecoders(t *testing.T) {
+	entry, err := decode(DockerJsonDecoder{}, LogEntry{Content: `{"log":"connection refused\n","stream":"stderr","time":"2024-01-15T10:20:30.123Z"}`})
+	require.NoError(t, err)
+	assert.Equal(t, "connection refused\n", entry.Content)
+	assert.Equal(t, StreamStderr, entry.Stream)
+
+	entry, err = decode(CriDecoder{}, LogEntry{Content: `2024-01-15T10:20:30.123456789Z stdout F GET /healthz 200`})
+	require.NoError(t, err)
+	assert.Equal(t, "GET /healthz 200", entry.Content)
+	assert.Equal(t, StreamStdout, entry.Stream)
+
+	_, err = decode(CriDecoder{}, LogEntry{Content: `stdout F`})
+	assert.Error(t, err)
+}
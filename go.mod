@@ -2,7 +2,11 @@ module github.com/prs-io/plexus-logparser
 
 go 1.21
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/klauspost/compress v1.17.11
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/text v0.22.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,191 @@
+package logparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binary fields larger than this are considered corrupt input rather than being allocated
+const journalMaxBinaryFieldSize = 1 << 20
+
+var (
+	journalFieldsMapping = map[string]string{
+		"_SYSTEMD_UNIT":     "unit",
+		"SYSLOG_IDENTIFIER": "identifier",
+		"_HOSTNAME":         "host",
+		"_PID":              "pid",
+	}
+)
+
+// JournalReader reads records produced by `journalctl -o export` or `journalctl -o json`.
+// The format is detected by the first byte of the stream.
+type JournalReader struct {
+	r        *bufio.Reader
+	detected bool
+	json     bool
+}
+
+func NewJournalReader(r io.Reader) *JournalReader {
+	return &JournalReader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record with a message. It returns io.EOF when there are no more records.
+func (j *JournalReader) Read() (LogEntry, error) {
+	if !j.detected {
+		if err := j.detect(); err != nil {
+			return LogEntry{}, err
+		}
+	}
+	for {
+		var fields map[string][]byte
+		var err error
+		if j.json {
+			fields, err = j.readJson()
+		} else {
+			fields, err = j.readExport()
+		}
+		if err != nil {
+			return LogEntry{}, err
+		}
+		if msg, ok := fields["MESSAGE"]; ok {
+			return journalEntry(fields, msg), nil
+		}
+	}
+}
+
+func (j *JournalReader) detect() error {
+	for {
+		b, err := j.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		if b[0] == ' ' || b[0] == '\n' || b[0] == '\r' || b[0] == '\t' {
+			_, _ = j.r.ReadByte()
+			continue
+		}
+		j.json = b[0] == '{'
+		j.detected = true
+		return nil
+	}
+}
+
+// https://systemd.io/JOURNAL_EXPORT_FORMATS/#journal-export-format
+func (j *JournalReader) readExport() (map[string][]byte, error) {
+	fields := map[string][]byte{}
+	for {
+		line, err := j.r.ReadBytes('\n')
+		switch {
+		case err == nil:
+			line = line[:len(line)-1]
+		case !errors.Is(err, io.EOF):
+			return nil, err
+		case len(line) == 0 && len(fields) > 0:
+			return fields, nil
+		case len(line) == 0:
+			return nil, io.EOF
+		}
+		if len(line) == 0 {
+			if len(fields) == 0 {
+				continue
+			}
+			return fields, nil
+		}
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			fields[string(line[:i])] = line[i+1:]
+			continue
+		}
+		var size uint64
+		if err = binary.Read(j.r, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("failed to read the size of the binary field %s: %w", line, err)
+		}
+		if size > journalMaxBinaryFieldSize {
+			return nil, fmt.Errorf("the binary field %s is too large: %d bytes", line, size)
+		}
+		value := make([]byte, size+1)
+		if _, err = io.ReadFull(j.r, value); err != nil {
+			return nil, fmt.Errorf("failed to read the binary field %s: %w", line, err)
+		}
+		if value[size] != '\n' {
+			return nil, fmt.Errorf("the binary field %s is not terminated by a newline", line)
+		}
+		fields[string(line)] = value[:size]
+	}
+}
+
+// https://systemd.io/JOURNAL_EXPORT_FORMATS/#journal-json-format
+func (j *JournalReader) readJson() (map[string][]byte, error) {
+	for {
+		line, err := j.r.ReadBytes('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0) {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		obj := map[string]json.RawMessage{}
+		if err = json.Unmarshal(line, &obj); err != nil {
+			return nil, fmt.Errorf(`failed to unmarshal journal entry "%s": %s`, line, err)
+		}
+		fields := make(map[string][]byte, len(obj))
+		for k, v := range obj {
+			if value, ok := journalJsonValue(v); ok {
+				fields[k] = value
+			}
+		}
+		return fields, nil
+	}
+}
+
+// a value is a string, an array of bytes if the field contains non-printable characters,
+// null if it is too large, or an array of those if the field is repeated
+func journalJsonValue(v json.RawMessage) ([]byte, bool) {
+	if string(v) == "null" {
+		return nil, false
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return []byte(s), true
+	}
+	var b []byte
+	var ints []int
+	if err := json.Unmarshal(v, &ints); err == nil {
+		b = make([]byte, len(ints))
+		for i, n := range ints {
+			b[i] = byte(n)
+		}
+		return b, true
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(v, &values); err == nil && len(values) > 0 {
+		return journalJsonValue(values[0])
+	}
+	return nil, false
+}
+
+func journalEntry(fields map[string][]byte, msg []byte) LogEntry {
+	entry := LogEntry{
+		Content: strings.TrimSuffix(string(msg), "\n"),
+		Level:   LevelByPriority(string(fields["PRIORITY"])),
+	}
+	if us, err := strconv.ParseInt(string(fields["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		entry.Timestamp = time.UnixMicro(us)
+	}
+	for k, name := range journalFieldsMapping {
+		if v := fields[k]; len(v) > 0 {
+			if entry.Fields == nil {
+				entry.Fields = map[string]string{}
+			}
+			entry.Fields[name] = string(v)
+		}
+	}
+	return entry
+}
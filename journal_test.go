@@ -0,0 +1,104 @@
+package logparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalReaderExport(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("__CURSOR=s=739ad463348b4ceca5a9e69c95a3c93f;i=4ece7\n")
+	buf.WriteString("__REALTIME_TIMESTAMP=1342540861416351\n")
+	buf.WriteString("PRIORITY=6\n")
+	buf.WriteString("_SYSTEMD_UNIT=systemd-logind.service\n")
+	buf.WriteString("_HOSTNAME=waldi\n")
+	buf.WriteString("MESSAGE=New session 1 of user root.\n")
+	buf.WriteString("\n")
+	buf.WriteString("__REALTIME_TIMESTAMP=1342540861421465\n")
+	buf.WriteString("_TRANSPORT=kernel\n")
+	buf.WriteString("\n")
+	buf.WriteString("PRIORITY=3\n")
+	buf.WriteString("SYSLOG_IDENTIFIER=app\n")
+	buf.WriteString("MESSAGE\n")
+	msg := "panic: boom\n\ngoroutine 1 [running]:\n\x01"
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, uint64(len(msg))))
+	buf.WriteString(msg + "\n")
+	buf.WriteString("_PID=42\n")
+
+	r := NewJournalReader(buf)
+	entry, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "New session 1 of user root.", entry.Content)
+	assert.Equal(t, LevelInfo, entry.Level)
+	assert.Equal(t, time.UnixMicro(1342540861416351), entry.Timestamp)
+	assert.Equal(t, map[string]string{"unit": "systemd-logind.service", "host": "waldi"}, entry.Fields)
+
+	entry, err = r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, msg, entry.Content)
+	assert.Equal(t, LevelError, entry.Level)
+	assert.True(t, entry.Timestamp.IsZero())
+	assert.Equal(t, map[string]string{"identifier": "app", "pid": "42"}, entry.Fields)
+
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestJournalReaderExportCorrupt(t *testing.T) {
+	read := func(size uint64, data string) error {
+		buf := bytes.NewBuffer(nil)
+		buf.WriteString("MESSAGE\n")
+		require.NoError(t, binary.Write(buf, binary.LittleEndian, size))
+		buf.WriteString(data)
+		_, err := NewJournalReader(buf).Read()
+		return err
+	}
+	assert.ErrorContains(t, read(1<<63, "boom\n"), "too large")
+	assert.ErrorIs(t, read(100, "boom\n"), io.ErrUnexpectedEOF)
+	assert.ErrorContains(t, read(2, "boom\n"), "not terminated")
+	assert.NoError(t, read(4, "boom\n"))
+
+	// not the export format: a line without = is read as a binary field
+	_, err := NewJournalReader(strings.NewReader("just some text\nand more text\n")).Read()
+	assert.Error(t, err)
+}
+
+func TestJournalReaderJson(t *testing.T) {
+	data := `{"__REALTIME_TIMESTAMP":"1342540861416351","PRIORITY":"4","_SYSTEMD_UNIT":"nginx.service","MESSAGE":"upstream timed out"}
+{"__REALTIME_TIMESTAMP":"1342540861416352","PRIORITY":"2","MESSAGE":[98,105,110,10]}
+{"__REALTIME_TIMESTAMP":"1342540861416353","MESSAGE":null}
+{"PRIORITY":["7","6"],"MESSAGE":["first","second"],"_SYSTEMD_UNIT":"kubelet.service"}`
+
+	r := NewJournalReader(strings.NewReader("\n" + data))
+	entry, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "upstream timed out", entry.Content)
+	assert.Equal(t, LevelWarning, entry.Level)
+	assert.Equal(t, time.UnixMicro(1342540861416351), entry.Timestamp)
+	assert.Equal(t, map[string]string{"unit": "nginx.service"}, entry.Fields)
+
+	entry, err = r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "bin", entry.Content)
+	assert.Equal(t, LevelCritical, entry.Level)
+	assert.Nil(t, entry.Fields)
+
+	entry, err = r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "first", entry.Content)
+	assert.Equal(t, LevelDebug, entry.Level)
+	assert.Equal(t, map[string]string{"unit": "kubelet.service"}, entry.Fields)
+
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewJournalReader(strings.NewReader(`{"MESSAGE":`)).Read()
+	assert.Error(t, err)
+}
@@ -1,10 +1,13 @@
 package logparser
 
 import (
+	"regexp"
 	"strings"
 	"unicode"
 )
 
+// Level values are stable: trace, notice and fatal were added after debug, so the order of the values
+// is not the order of severity. Use SeverityNumber to compare levels.
 type Level int
 
 const (
@@ -14,9 +17,9 @@ const (
 	LevelWarning
 	LevelInfo
 	LevelDebug
-
-	maxLineLenForGuessingLevel = 255
-	guessLevelInFields         = 7
+	LevelTrace
+	LevelNotice
+	LevelFatal
 )
 
 func (l Level) String() string {
@@ -31,16 +34,82 @@ func (l Level) String() string {
 		return "info"
 	case LevelDebug:
 		return "debug"
+	case LevelTrace:
+		return "trace"
+	case LevelNotice:
+		return "notice"
+	case LevelFatal:
+		return "fatal"
 	}
 	return "unknown"
 }
 
+// SeverityNumber maps the level to the OpenTelemetry SeverityNumber, it also defines the order of the levels.
+// Notice and critical are mapped to the second step of their ranges (INFO2 and FATAL2) to keep them distinct.
+func (l Level) SeverityNumber() int {
+	switch l {
+	case LevelTrace:
+		return 1
+	case LevelDebug:
+		return 5
+	case LevelInfo:
+		return 9
+	case LevelNotice:
+		return 10
+	case LevelWarning:
+		return 13
+	case LevelError:
+		return 17
+	case LevelFatal:
+		return 21
+	case LevelCritical:
+		return 22
+	}
+	return 0
+}
+
+// coarseLevel maps the levels added later to the closest original ones: trace to debug, notice to info
+// and fatal to critical, as the guessed levels were reported before the finer levels were introduced
+func coarseLevel(l Level) Level {
+	switch l {
+	case LevelTrace:
+		return LevelDebug
+	case LevelNotice:
+		return LevelInfo
+	case LevelFatal:
+		return LevelCritical
+	}
+	return l
+}
+
+func LevelFromSeverityNumber(n int) Level {
+	switch {
+	case n <= 0 || n > 24:
+		return LevelUnknown
+	case n <= 4:
+		return LevelTrace
+	case n <= 8:
+		return LevelDebug
+	case n == 9:
+		return LevelInfo
+	case n <= 12:
+		return LevelNotice
+	case n <= 16:
+		return LevelWarning
+	case n <= 20:
+		return LevelError
+	case n == 21:
+		return LevelFatal
+	}
+	return LevelCritical
+}
+
 var (
 	glogLevelsMapping = map[byte]Level{
 		'I': LevelInfo,
 		'W': LevelWarning,
 		'E': LevelError,
-		'F': LevelCritical,
+		'F': LevelFatal,
 	}
 	priority2Levels = map[string]Level{
 		"0": LevelCritical,
@@ -63,85 +132,189 @@ func LevelByPriority(priority string) Level {
 
 func LevelFromString(s string) Level {
 	switch strings.ToLower(s) {
-	case "critical":
+	case "critical", "crit", "emergency", "emerg", "alert":
 		return LevelCritical
-	case "error":
+	case "fatal", "ftl", "panic", "dpanic":
+		return LevelFatal
+	case "error", "err", "eror":
 		return LevelError
-	case "warning":
+	case "warning", "warn", "wrn":
 		return LevelWarning
-	case "info":
+	case "notice":
+		return LevelNotice
+	case "info", "inf", "information", "informational":
 		return LevelInfo
-	case "debug":
+	case "debug", "dbg":
 		return LevelDebug
+	case "trace", "trc":
+		return LevelTrace
 	default:
 		return LevelUnknown
 	}
 }
 
+// GuessLevel detects the level of the line using the built-in detectors, see NewLevelGuesser
 func GuessLevel(line string) Level {
-	if len(line) > maxLineLenForGuessingLevel {
-		line = line[:maxLineLenForGuessingLevel]
-	}
-	fields := strings.Fields(line)
-	if len(fields) == 0 {
-		return LevelUnknown
+	return defaultLevelGuesser.Guess(line)
+}
+
+func GuessLevelEvidence(line string) LevelEvidence {
+	return defaultLevelGuesser.GuessEvidence(line)
+}
+
+const (
+	// a level token in brackets, in upper case, followed by a colon or in the level= key
+	levelConfidenceStrong = 0.9
+	// a level word preceding any free text or following a prefix, e.g. "myhost kernel: error reading sector"
+	levelConfidencePositional = 0.7
+	// a level word inside free text or a word starting with a level name, e.g. "no errors found"
+	levelConfidenceWeak = 0.3
+)
+
+func isLevelSeparator(c byte) bool {
+	return c == ']' || c == ')' || c == ';' || c == '|' || c == ':' || c == ',' || c == '.'
+}
+
+// keywordLevelDetector looks for level names and their abbreviations in the fields.
+// Tokens marked as levels by their form or position are preferred over the ones found in free text.
+type keywordLevelDetector struct{}
+
+func (d keywordLevelDetector) DetectLevel(line string, fields []string) Level {
+	if e := d.DetectLevelEvidence(line, fields); e.Confidence >= defaultMinLevelConfidence {
+		return e.Level
 	}
-	limit := len(fields)
-	if limit > guessLevelInFields {
-		limit = guessLevelInFields
-	}
-
-	if l := tryGlog(fields); l != LevelUnknown {
-		return l
-	}
-
-	for _, f := range fields[:limit] {
-		subfields := strings.FieldsFunc(f, func(r rune) bool {
-			return r == ']' || r == ')' || r == ';' || r == '|' || r == ':' || r == ',' || r == '.'
-		})
-		for _, sf := range subfields {
-			sf = strings.TrimLeft(strings.ToLower(sf), "\"[(<'")
-			sf = strings.TrimPrefix(sf, "level=")
-
-			if l := len(sf); l == 3 {
-				switch sf {
-				case "dbg", "trc":
-					return LevelDebug
-				case "inf":
-					return LevelInfo
-				case "wrn":
-					return LevelWarning
-				case "err":
-					return LevelError
-				case "ftl":
-					return LevelCritical
+	return LevelUnknown
+}
+
+func (d keywordLevelDetector) DetectLevelEvidence(_ string, fields []string) LevelEvidence {
+	best := LevelEvidence{Position: -1}
+	freeText, afterPrefix := false, false
+	for i, f := range fields {
+		start := -1
+		for j := 0; j <= len(f); j++ {
+			if j < len(f) && !isLevelSeparator(f[j]) {
+				if start < 0 {
+					start = j
 				}
-			} else if l >= 4 {
-				switch sf[:4] {
-				case "debu":
-					return LevelDebug
-				case "info", "noti":
-					return LevelInfo
-				case "warn":
-					return LevelWarning
-				case "erro":
-					return LevelError
-				case "crit":
+				continue
+			}
+			if start < 0 {
+				continue
+			}
+			token := f[start:j]
+			start = -1
+			sf := strings.TrimRight(strings.TrimLeft(token, "\"[(<'"), "\"'>!")
+			if strings.HasPrefix(strings.ToLower(sf), "level=") {
+				sf = sf[len("level="):]
+			}
+			level := keywordLevel(strings.ToLower(sf))
+			if level == LevelUnknown {
+				continue
+			}
+			confidence := levelConfidenceWeak
+			switch {
+			case LevelFromString(sf) != level:
+			case sf != token && !strings.HasPrefix(token, "\"") && !strings.HasPrefix(token, "'"):
+				confidence = levelConfidenceStrong
+			case j < len(f) && (f[j] == ']' || f[j] == ')' || f[j] == ':'):
+				confidence = levelConfidenceStrong
+			case len(sf) >= 3 && strings.ToUpper(sf) == sf:
+				confidence = levelConfidenceStrong
+			case !freeText, afterPrefix && token == f[:j]:
+				confidence = levelConfidencePositional
+			}
+			if confidence > best.Confidence {
+				best = LevelEvidence{Level: level, Token: token, Position: i, Confidence: confidence}
+			}
+		}
+		afterPrefix = isPrefixField(f)
+		if !afterPrefix && isFreeTextWord(f) {
+			freeText = true
+		}
+	}
+	return best
+}
+
+// a host or program tag ending the prefix of the line: "kernel:", "sshd[12]:" or the "|" separator of docker compose
+func isPrefixField(f string) bool {
+	if f == "|" {
+		return true
+	}
+	name, ok := strings.CutSuffix(f, ":")
+	if i := strings.IndexByte(name, '['); i > 0 && strings.HasSuffix(name, "]") {
+		name = name[:i]
+	}
+	if !ok || name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '/') {
+			return false
+		}
+	}
+	return true
+}
+
+// free text consists of lower-case words: "no", "failed", "to", etc.
+func isFreeTextWord(f string) bool {
+	f = strings.TrimRight(f, ",.;:!?")
+	if len(f) < 2 {
+		return false
+	}
+	for i := 0; i < len(f); i++ {
+		if f[i] < 'a' || f[i] > 'z' {
+			return false
+		}
+	}
+	return keywordLevel(f) == LevelUnknown
+}
+
+func keywordLevel(sf string) Level {
+	if l := len(sf); l == 3 {
+		switch sf {
+		case "dbg":
+			return LevelDebug
+		case "trc":
+			return LevelTrace
+		case "inf":
+			return LevelInfo
+		case "wrn":
+			return LevelWarning
+		case "err":
+			return LevelError
+		case "ftl":
+			return LevelFatal
+		}
+	} else if l >= 4 {
+		switch sf[:4] {
+		case "trac":
+			// but not traceback, tracing, etc.
+			if sf == "trace" {
+				return LevelTrace
+			}
+		case "debu":
+			return LevelDebug
+		case "info":
+			return LevelInfo
+		case "noti":
+			return LevelNotice
+		case "warn":
+			return LevelWarning
+		case "erro":
+			return LevelError
+		case "crit":
+			return LevelCritical
+		case "emer", "fata", "aler":
+			if l >= 5 {
+				switch sf[:5] {
+				case "emerg", "alert":
 					return LevelCritical
-				case "emer", "fata", "aler":
-					if l >= 5 {
-						switch sf[:5] {
-						case "emerg", "fatal", "alert":
-							return LevelCritical
-						}
-					}
+				case "fatal":
+					return LevelFatal
 				}
 			}
 		}
 	}
-	if l := guessRedisLevel(fields); l != LevelUnknown {
-		return l
-	}
 	return LevelUnknown
 }
 
@@ -201,17 +374,132 @@ func redisCharToLevel(level string) Level {
 	return LevelUnknown
 }
 
-// todo
-//
-// python
-//
-//   loglevels: DEBUG, INFO, WARNING, ERROR,CRITICAL
-// pylogging:
-//  default "%(levelname)s:%(name)s:%(message)s" https://github.com/python/cpython/blob/master/Lib/logging/__init__.py#L502
-// django:
-//   {levelname} {message}
-//   {asctime} {module} [{levelname}] okserver
-//   [%(asctime)s] %(levelname)s
-//   %(levelname)s %(asctime)s %(module)s: %(message)s
-// asctime: %(asctime)s Human-readable time when the LogRecord was created. By default this is of the form ‘2003-07-08 16:49:45,896’ (the numbers after the comma are millisecond portion of the time).
-//
+var (
+	pythonLevelNames = `(DEBUG|INFO|WARNING|WARN|ERROR|CRITICAL|FATAL)`
+
+	// %(levelname)s:%(name)s:%(message)s (the default format of the logging module)
+	// ERROR:django.request:Internal Server Error: /article
+	pythonDefaultFormat = regexp.MustCompile(`^` + pythonLevelNames + `:[\w.\-]*:`)
+
+	// formats starting with %(asctime)s, the level is the first level name after it:
+	// 2003-07-08 16:49:45,896 views [CRITICAL] okserver (django)
+	// [2003-07-08 16:49:45,896] ERROR [django.request:222] Internal Server Error (django)
+	// 2003-07-08 16:49:45,896 - app.module - 1234 - MainThread - WARNING - retrying
+	// [2024-01-15 10:20:30,123: ERROR/ForkPoolWorker-2] Task tasks.send[f3b1] raised unexpected exception (celery)
+	pythonAsctimeFormat = regexp.MustCompile(`^\[?\d{4}-\d\d-\d\d \d\d:\d\d:\d\d,\d{3}\b.*?\b` + pythonLevelNames + `\b`)
+
+	// [2024-01-15 10:20:30 +0000] [7] [CRITICAL] WORKER TIMEOUT (pid:8)
+	gunicornFormat = regexp.MustCompile(`^\[\d{4}-\d\d-\d\d \d\d:\d\d:\d\d [+-]\d{4}\] \[\d+\] \[` + pythonLevelNames + `\]`)
+
+	pythonLevelsMapping = map[string]Level{
+		"DEBUG":    LevelDebug,
+		"INFO":     LevelInfo,
+		"WARNING":  LevelWarning,
+		"WARN":     LevelWarning,
+		"ERROR":    LevelError,
+		"CRITICAL": LevelCritical,
+		"FATAL":    LevelFatal,
+	}
+)
+
+// Python logging, Django, Celery and Gunicorn
+func tryPython(line string) Level {
+	var formats []*regexp.Regexp
+	switch c := line[0]; {
+	case c >= 'A' && c <= 'Z':
+		formats = []*regexp.Regexp{pythonDefaultFormat}
+	case c == '[' || c >= '0' && c <= '9':
+		formats = []*regexp.Regexp{pythonAsctimeFormat, gunicornFormat}
+	}
+	for _, re := range formats {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return pythonLevelsMapping[m[1]]
+		}
+	}
+	return LevelUnknown
+}
+
+var (
+	// PostgreSQL separates the severity from the message with two spaces, the prefix is defined by log_line_prefix:
+	// 2024-01-15 10:20:30.123 UTC [1234] app@db ERROR:  relation "users" does not exist at character 15
+	// 2024-01-15 10:20:30.123 UTC [1234] app@db STATEMENT:  SELECT * FROM users
+	// the prefix must start with a timestamp or a [pid], so a message that merely contains "DETAIL:  " is not matched
+	postgresFormat = regexp.MustCompile(`^(?:(?:\d{4}-\d\d-\d\d[ T]\d\d:\d\d:\d\d\S*|\[\d+\]:?)(?: \S+){0,5} )?(LOG|FATAL|PANIC|ERROR|WARNING|NOTICE|INFO|DEBUG[1-5]|DETAIL|HINT|STATEMENT|CONTEXT|QUERY|LOCATION):  `)
+
+	// 2024-01-15T10:20:30.123456Z 0 [ERROR] [MY-010119] [Server] Aborting (MySQL 8)
+	// 2024-01-15T10:20:30.123456Z 0 [Note] InnoDB: Buffer pool(s) load completed (MySQL 5.7)
+	// 2024-01-15 10:20:30 0 [Note] InnoDB: Starting shutdown... (MariaDB)
+	mysqlFormat = regexp.MustCompile(`^\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d(\.\d+)?Z? +\d+ \[(System|Note|Warning|ERROR|Error)\]`)
+
+	// {"t":{"$date":"2024-01-15T10:20:30.123+00:00"},"s":"E",  "c":"STORAGE",  "id":22435, ...}
+	mongodbFormat = regexp.MustCompile(`^\{"t":\{"\$date":"[^"]+"\},"s":"(F|E|W|I|D[1-5]?)"`)
+
+	postgresLevelsMapping = map[string]Level{
+		"PANIC":   LevelCritical,
+		"FATAL":   LevelFatal,
+		"ERROR":   LevelError,
+		"WARNING": LevelWarning,
+		"NOTICE":  LevelNotice,
+		"INFO":    LevelInfo,
+		"LOG":     LevelInfo,
+	}
+	mysqlLevelsMapping = map[string]Level{
+		"System":  LevelInfo,
+		"Note":    LevelInfo,
+		"Warning": LevelWarning,
+		"ERROR":   LevelError,
+		"Error":   LevelError,
+	}
+	mongodbLevelsMapping = map[byte]Level{
+		'F': LevelFatal,
+		'E': LevelError,
+		'W': LevelWarning,
+		'I': LevelInfo,
+		'D': LevelDebug,
+	}
+)
+
+// PostgreSQL, MySQL (MariaDB) and MongoDB
+func tryDatabase(line string) Level {
+	if strings.HasPrefix(line, `{"t":`) {
+		if m := mongodbFormat.FindStringSubmatch(line); m != nil {
+			return mongodbLevelsMapping[m[1][0]]
+		}
+		return LevelUnknown
+	}
+	if severity := postgresSeverity(line); severity != "" {
+		if strings.HasPrefix(severity, "DEBUG") {
+			return LevelDebug
+		}
+		return postgresLevelsMapping[severity]
+	}
+	if line[0] >= '0' && line[0] <= '9' {
+		if m := mysqlFormat.FindStringSubmatch(line); m != nil {
+			return mysqlLevelsMapping[m[2]]
+		}
+	}
+	return LevelUnknown
+}
+
+func postgresSeverity(line string) string {
+	if !strings.Contains(line, ":  ") {
+		return ""
+	}
+	if m := postgresFormat.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// isPostgresContinuation reports whether the line adds details to the previous PostgreSQL message
+func isPostgresContinuation(line string) bool {
+	return isPostgresDetail(postgresSeverity(line))
+}
+
+func isPostgresDetail(severity string) bool {
+	switch severity {
+	case "DETAIL", "HINT", "STATEMENT", "CONTEXT", "QUERY", "LOCATION":
+		return true
+	}
+	return false
+}
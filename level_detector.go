@@ -0,0 +1,207 @@
+package logparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultMaxLineLenForGuessingLevel = 255
+	defaultGuessLevelInFields         = 7
+	defaultMinLevelConfidence         = 0.5
+)
+
+// names of the built-in detectors in the default order
+const (
+	LevelDetectorGlog     = "glog"
+	LevelDetectorDatabase = "database"
+	LevelDetectorPython   = "python"
+	LevelDetectorKeyword  = "keyword"
+	LevelDetectorRedis    = "redis"
+)
+
+// LevelDetector detects the level of a line. The line is cut to the scanned prefix,
+// fields are the first whitespace-separated fields of the prefix.
+type LevelDetector interface {
+	DetectLevel(line string, fields []string) Level
+}
+
+// LevelEvidence describes how the level was detected
+type LevelEvidence struct {
+	Level Level
+	// Detector is the name of the detector
+	Detector string
+	// Token is the token the level was detected by and Position is the index of the field containing it,
+	// they are empty (-1) if the detector matches the line as a whole
+	Token    string
+	Position int
+	// Confidence is between 0 and 1, detectors matching a specific format are fully confident
+	Confidence float64
+}
+
+// LevelEvidenceDetector is implemented by detectors that can tell how confident they are
+type LevelEvidenceDetector interface {
+	DetectLevelEvidence(line string, fields []string) LevelEvidence
+}
+
+type LevelDetectorFunc func(line string, fields []string) Level
+
+func (f LevelDetectorFunc) DetectLevel(line string, fields []string) Level {
+	return f(line, fields)
+}
+
+// LevelPrefixes detects the level by the prefix of the line, e.g. {"[E]": LevelError, "<3>": LevelError}
+type LevelPrefixes map[string]Level
+
+func (p LevelPrefixes) DetectLevel(line string, _ []string) Level {
+	var longest string
+	for prefix := range p {
+		if len(prefix) > len(longest) && strings.HasPrefix(line, prefix) {
+			longest = prefix
+		}
+	}
+	if longest == "" {
+		return LevelUnknown
+	}
+	return p[longest]
+}
+
+// LevelRegexp detects the level of the lines matching Regexp. If Level is not set,
+// the first submatch is used as the level name, e.g. `^\[(\w+)\]`.
+type LevelRegexp struct {
+	Regexp *regexp.Regexp
+	Level  Level
+}
+
+func (r LevelRegexp) DetectLevel(line string, _ []string) Level {
+	m := r.Regexp.FindStringSubmatch(line)
+	switch {
+	case m == nil:
+		return LevelUnknown
+	case r.Level != LevelUnknown:
+		return r.Level
+	case len(m) > 1:
+		return LevelFromString(m[1])
+	}
+	return LevelUnknown
+}
+
+type namedLevelDetector struct {
+	name     string
+	detector LevelDetector
+}
+
+// LevelGuesser runs the registered detectors in order and returns the first known level.
+// It must be configured before use and must not be changed while in use.
+type LevelGuesser struct {
+	// MaxLineLen is the length of the line prefix passed to the detectors
+	MaxLineLen int
+	// MaxFields is the number of fields passed to the detectors
+	MaxFields int
+	// MinConfidence is the confidence below which the evidence of a detector is ignored
+	MinConfidence float64
+	// FineLevels enables guessing the trace, notice and fatal levels,
+	// otherwise they are reported as debug, info and critical
+	FineLevels bool
+
+	detectors []namedLevelDetector
+}
+
+var defaultLevelGuesser = NewLevelGuesser()
+
+// NewLevelGuesser returns a guesser with the built-in detectors: glog, database, python, keyword and redis
+func NewLevelGuesser() *LevelGuesser {
+	g := &LevelGuesser{
+		MaxLineLen:    defaultMaxLineLenForGuessingLevel,
+		MaxFields:     defaultGuessLevelInFields,
+		MinConfidence: defaultMinLevelConfidence,
+	}
+	g.Register(LevelDetectorGlog, LevelDetectorFunc(func(_ string, fields []string) Level { return tryGlog(fields) }))
+	g.Register(LevelDetectorDatabase, LevelDetectorFunc(func(line string, _ []string) Level { return tryDatabase(line) }))
+	g.Register(LevelDetectorPython, LevelDetectorFunc(func(line string, _ []string) Level { return tryPython(line) }))
+	g.Register(LevelDetectorKeyword, keywordLevelDetector{})
+	g.Register(LevelDetectorRedis, LevelDetectorFunc(func(_ string, fields []string) Level { return guessRedisLevel(fields) }))
+	return g
+}
+
+// Register adds the detector to the end of the list or replaces the detector with the same name
+func (g *LevelGuesser) Register(name string, detector LevelDetector) {
+	for i := range g.detectors {
+		if g.detectors[i].name == name {
+			g.detectors[i].detector = detector
+			return
+		}
+	}
+	g.detectors = append(g.detectors, namedLevelDetector{name: name, detector: detector})
+}
+
+// Detectors returns the names of the registered detectors in order
+func (g *LevelGuesser) Detectors() []string {
+	res := make([]string, 0, len(g.detectors))
+	for _, d := range g.detectors {
+		res = append(res, d.name)
+	}
+	return res
+}
+
+// SetOrder defines the order of the detectors, the detectors not listed are disabled
+func (g *LevelGuesser) SetOrder(names ...string) error {
+	detectors := make([]namedLevelDetector, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, d := range g.detectors {
+			if d.name == name {
+				detectors = append(detectors, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown level detector: %s", name)
+		}
+	}
+	g.detectors = detectors
+	return nil
+}
+
+func (g *LevelGuesser) Guess(line string) Level {
+	return g.GuessEvidence(line).Level
+}
+
+// GuessEvidence returns the level along with the evidence of the first detector confident enough
+func (g *LevelGuesser) GuessEvidence(line string) LevelEvidence {
+	e := g.guessEvidence(line)
+	if !g.FineLevels {
+		e.Level = coarseLevel(e.Level)
+	}
+	return e
+}
+
+func (g *LevelGuesser) guessEvidence(line string) LevelEvidence {
+	unknown := LevelEvidence{Position: -1}
+	if g.MaxLineLen > 0 && len(line) > g.MaxLineLen {
+		line = line[:g.MaxLineLen]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return unknown
+	}
+	if g.MaxFields > 0 && len(fields) > g.MaxFields {
+		fields = fields[:g.MaxFields]
+	}
+	for _, d := range g.detectors {
+		if ed, ok := d.detector.(LevelEvidenceDetector); ok {
+			e := ed.DetectLevelEvidence(line, fields)
+			if e.Level != LevelUnknown && e.Confidence >= g.MinConfidence {
+				e.Detector = d.name
+				return e
+			}
+			continue
+		}
+		if l := d.detector.DetectLevel(line, fields); l != LevelUnknown {
+			return LevelEvidence{Level: l, Detector: d.name, Position: -1, Confidence: 1}
+		}
+	}
+	return unknown
+}
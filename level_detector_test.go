@@ -0,0 +1,113 @@
+package logparser
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelGuesser(t *testing.T) {
+	g := NewLevelGuesser()
+	assert.Equal(t, []string{LevelDetectorGlog, LevelDetectorDatabase, LevelDetectorPython, LevelDetectorKeyword, LevelDetectorRedis}, g.Detectors())
+	assert.Equal(t, LevelUnknown, g.Guess("[E] disk is full"))
+
+	g.Register("in-house", LevelPrefixes{"[E]": LevelError, "[W]": LevelWarning, "!!!": LevelCritical, "!": LevelWarning})
+	g.Register("kernel", LevelRegexp{Regexp: regexp.MustCompile(`^<[0-3]>`), Level: LevelError})
+	g.Register("bracketed", LevelRegexp{Regexp: regexp.MustCompile(`^\((\w+)\) `)})
+	assert.Equal(t, LevelError, g.Guess("[E] disk is full"))
+	assert.Equal(t, LevelCritical, g.Guess("!!! replication is broken"))
+	assert.Equal(t, LevelWarning, g.Guess("! slow query"))
+	assert.Equal(t, LevelError, g.Guess("<3> nvme0: I/O timeout"))
+	assert.Equal(t, LevelWarning, g.Guess("(warn) cache miss ratio is high"))
+	assert.Equal(t, LevelUnknown, g.Guess("(unusual) cache miss ratio is high"))
+
+	// the built-in detectors go first
+	assert.Equal(t, LevelInfo, g.Guess("[E] INFO: done"))
+	require.NoError(t, g.SetOrder("in-house", LevelDetectorKeyword))
+	assert.Equal(t, LevelError, g.Guess("[E] INFO: done"))
+	assert.Equal(t, LevelUnknown, g.Guess("I0430 11:58:31.792717 1 cluster.go:337] done"))
+	assert.Error(t, g.SetOrder("glog", "unknown"))
+
+	g = NewLevelGuesser()
+	g.MaxFields = 2
+	assert.Equal(t, LevelUnknown, g.Guess("2024-01-15 10:20:30.123 ERROR boom"))
+	g.MaxFields = 3
+	assert.Equal(t, LevelError, g.Guess("2024-01-15 10:20:30.123 ERROR boom"))
+	g.MaxLineLen = 10
+	assert.Equal(t, LevelUnknown, g.Guess("2024-01-15 10:20:30.123 ERROR boom"))
+}
+
+func TestMultilineCollectorLevelGuesser(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	g := NewLevelGuesser()
+	g.Register("in-house", LevelPrefixes{"!!!": LevelCritical})
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithLevelGuesser(g))
+	m.Add(LogEntry{Content: "!!! replication is broken"})
+	msg := <-m.Messages
+	assert.Equal(t, LevelCritical, msg.Level)
+	assert.Equal(t, LevelSourceContent, msg.LevelSource)
+}
+
+func TestGuessLevelEvidence(t *testing.T) {
+	e := GuessLevelEvidence("2024-01-15 10:20:30 [error] connection refused")
+	assert.Equal(t, LevelEvidence{Level: LevelError, Detector: LevelDetectorKeyword, Token: "[error", Position: 2, Confidence: levelConfidenceStrong}, e)
+
+	e = GuessLevelEvidence("2024-01-15 10:20:30 error: connection refused")
+	assert.Equal(t, LevelEvidence{Level: LevelError, Detector: LevelDetectorKeyword, Token: "error", Position: 2, Confidence: levelConfidenceStrong}, e)
+
+	e = GuessLevelEvidence("Oct 11 22:14:15 myhost kernel: error reading sector")
+	assert.Equal(t, LevelEvidence{Level: LevelError, Detector: LevelDetectorKeyword, Token: "error", Position: 5, Confidence: levelConfidencePositional}, e)
+
+	e = GuessLevelEvidence("I0115 10:20:30.123456 1 controller.go:42] Starting controller")
+	assert.Equal(t, LevelEvidence{Level: LevelInfo, Detector: LevelDetectorGlog, Position: -1, Confidence: 1}, e)
+
+	// free text
+	assert.Equal(t, LevelEvidence{Position: -1}, GuessLevelEvidence("retrying the request after an error"))
+	e = keywordLevelDetector{}.DetectLevelEvidence("", strings.Fields("retrying the request after an error"))
+	assert.Equal(t, LevelEvidence{Level: LevelError, Token: "error", Position: 5, Confidence: levelConfidenceWeak}, e)
+
+	g := NewLevelGuesser()
+	g.MinConfidence = 0
+	assert.Equal(t, LevelError, g.Guess("no errors found"))
+
+	// a bracketed token wins over a level word in free text
+	assert.Equal(t, LevelWarning, GuessLevel("cache info is stale [WARN]"))
+}
+
+func TestGuessLevelCorpus(t *testing.T) {
+	data, err := os.ReadFile("testdata/levels.tsv")
+	require.NoError(t, err)
+
+	fine := NewLevelGuesser()
+	fine.FineLevels = true
+	var total, detected, correct int
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		label, text, ok := strings.Cut(line, "\t")
+		require.True(t, ok, line)
+		expected := LevelFromString(label)
+		actual := GuessLevel(text)
+		total++
+		if actual != LevelUnknown {
+			detected++
+			if actual == coarseLevel(expected) {
+				correct++
+			}
+		}
+		assert.Equal(t, coarseLevel(expected), actual, text)
+		assert.Equal(t, expected, fine.Guess(text), text)
+	}
+	precision := float64(correct) / float64(detected)
+	t.Logf("lines: %d, detected: %d, precision: %.2f", total, detected, precision)
+	assert.GreaterOrEqual(t, precision, 0.95)
+}
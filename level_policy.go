@@ -0,0 +1,70 @@
+package logparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type LevelSource string
+
+const (
+	LevelSourceNone LevelSource = ""
+	// LevelSourceField is the level provided along with the entry: by the caller or by a decoder
+	// (a JSON or logfmt level field, a syslog priority, an HTTP status)
+	LevelSourceField LevelSource = "field"
+	// LevelSourceContent is the level guessed from the content of the first line of the message
+	LevelSourceContent LevelSource = "content"
+	// LevelSourceStream means that the entry was written to stderr, which is considered a warning
+	LevelSourceStream LevelSource = "stream"
+)
+
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// DefaultLevelPolicy prefers the level provided with the entry, so the levels set by structured decoders
+// are authoritative, and guesses the level from the content otherwise. The stream is not consulted by default,
+// as many applications write all their logs to stderr.
+var DefaultLevelPolicy = []LevelSource{LevelSourceField, LevelSourceContent}
+
+// ContentFirstLevelPolicy guesses the level from the content and falls back to the level provided with the entry.
+var ContentFirstLevelPolicy = []LevelSource{LevelSourceContent, LevelSourceField}
+
+// ParseLevelPolicy parses a comma-separated list of level sources, e.g. "field,content,stream"
+func ParseLevelPolicy(s string) ([]LevelSource, error) {
+	var policy []LevelSource
+	for _, name := range strings.Split(s, ",") {
+		switch src := LevelSource(strings.TrimSpace(name)); src {
+		case LevelSourceField, LevelSourceContent, LevelSourceStream:
+			policy = append(policy, src)
+		default:
+			return nil, fmt.Errorf("unknown level source: %q", name)
+		}
+	}
+	return policy, nil
+}
+
+// resolveLevel returns the level provided by the first source of the policy that knows it
+func resolveLevel(policy []LevelSource, guesser *LevelGuesser, entry LogEntry) (Level, LevelSource) {
+	if entry.LevelFixed && entry.Level != LevelUnknown {
+		return entry.Level, LevelSourceField
+	}
+	for _, src := range policy {
+		level := LevelUnknown
+		switch src {
+		case LevelSourceField:
+			level = entry.Level
+		case LevelSourceContent:
+			level = guesser.Guess(entry.Content)
+		case LevelSourceStream:
+			if entry.Stream == StreamStderr {
+				level = LevelWarning
+			}
+		}
+		if level != LevelUnknown {
+			return level, src
+		}
+	}
+	return LevelUnknown, LevelSourceNone
+}
@@ -0,0 +1,78 @@
+package logparser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLevel(t *testing.T) {
+	check := func(policy []LevelSource, entry LogEntry, level Level, src LevelSource) {
+		t.Helper()
+		l, s := resolveLevel(policy, defaultLevelGuesser, entry)
+		assert.Equal(t, level, l)
+		assert.Equal(t, src, s)
+	}
+	check(DefaultLevelPolicy, LogEntry{Content: "ERROR boom", Level: LevelInfo}, LevelInfo, LevelSourceField)
+	check(DefaultLevelPolicy, LogEntry{Content: "ERROR boom"}, LevelError, LevelSourceContent)
+	check(DefaultLevelPolicy, LogEntry{Content: "boom", Stream: StreamStderr}, LevelUnknown, LevelSourceNone)
+
+	check(ContentFirstLevelPolicy, LogEntry{Content: "ERROR boom", Level: LevelInfo}, LevelError, LevelSourceContent)
+	check(ContentFirstLevelPolicy, LogEntry{Content: "boom", Level: LevelInfo}, LevelInfo, LevelSourceField)
+
+	policy := []LevelSource{LevelSourceField, LevelSourceContent, LevelSourceStream}
+	check(policy, LogEntry{Content: "ERROR boom", Stream: StreamStderr}, LevelError, LevelSourceContent)
+	check(policy, LogEntry{Content: "boom", Stream: StreamStderr}, LevelWarning, LevelSourceStream)
+	check(policy, LogEntry{Content: "boom", Stream: StreamStdout}, LevelUnknown, LevelSourceNone)
+}
+
+func TestParseLevelPolicy(t *testing.T) {
+	policy, err := ParseLevelPolicy("field, content,stream")
+	require.NoError(t, err)
+	assert.Equal(t, []LevelSource{LevelSourceField, LevelSourceContent, LevelSourceStream}, policy)
+	_, err = ParseLevelPolicy("field,priority")
+	assert.Error(t, err)
+	_, err = ParseLevelPolicy("")
+	assert.Error(t, err)
+}
+
+func TestStructuredLevelIsAuthoritative(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	entry, err := LogfmtDecoder{}.DecodeEntry(LogEntry{Content: `level=info msg="error rate is back to normal"`})
+	require.NoError(t, err)
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	m.Add(entry)
+	msg := <-m.Messages
+	assert.Equal(t, "error rate is back to normal", msg.Content)
+	assert.Equal(t, LevelInfo, msg.Level)
+	assert.Equal(t, LevelSourceField, msg.LevelSource)
+}
+
+func TestMultilineCollectorLevelPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	m.Add(LogEntry{Content: "Exception in worker", Stream: StreamStderr})
+	msg := <-m.Messages
+	assert.Equal(t, LevelUnknown, msg.Level)
+
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithLevelPolicy(LevelSourceField, LevelSourceContent, LevelSourceStream))
+	m.Add(LogEntry{Content: "Exception in worker", Stream: StreamStderr})
+	m.Add(LogEntry{Content: "  at main", Stream: StreamStderr})
+	msg = <-m.Messages
+	assert.Equal(t, "Exception in worker\n  at main", msg.Content)
+	assert.Equal(t, LevelWarning, msg.Level)
+	assert.Equal(t, LevelSourceStream, msg.LevelSource)
+
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithLevelPolicy(LevelSourceContent))
+	m.Add(LogEntry{Content: "request failed", Level: LevelError, Stream: StreamStderr})
+	msg = <-m.Messages
+	require.Equal(t, LevelUnknown, msg.Level)
+	assert.Equal(t, LevelSourceNone, msg.LevelSource)
+}
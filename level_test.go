@@ -41,3 +41,126 @@ func TestGuessLevel(t *testing.T) {
 	assert.Equal(t, LevelCritical, GuessLevel(`2022/05/14 07:08:37 [crit] 6689#6689: *16721837 SSL_do_handshake() failed (SSL: error:1420918C:SSL routines:tls_early_post_process_client_hello:version too low) while SSL handshaking`))
 	assert.Equal(t, LevelError, GuessLevel(`2009/01/01 19:45:44 [error]  29874#0: *98 open() "/var/www/one/nonexistent.html" failed (2: No such file or directory), client: 11.22.33.44, server: one.org, request: "GET /nonexistent.html HTTP/1.1", host: "one.org"`))
 }
+
+func TestGuessLevelPython(t *testing.T) {
+	for line, level := range map[string]Level{
+		// logging defaults
+		`ERROR:root:boom`: LevelError,
+		`WARNING:urllib3.connectionpool:Retrying (Retry(total=2))`: LevelWarning,
+		`CRITICAL:app:out of memory`:                               LevelCritical,
+		`DEBUG:asyncio:Using selector: EpollSelector`:              LevelDebug,
+		`INFO:root:Error count: 0`:                                 LevelInfo,
+
+		// django
+		`ERROR Internal Server Error: /article`:                                                                  LevelError,
+		`2003-07-08 16:49:45,896 views [CRITICAL] okserver`:                                                      LevelCritical,
+		`[2003-07-08 16:49:45,896] ERROR [django.request:222] Internal Server Error: /article`:                   LevelError,
+		`WARNING 2003-07-08 16:49:45,896 views: Not Found: /favicon.ico`:                                         LevelWarning,
+		`2003-07-08 16:49:45,896 - app.tasks.billing - 1234 - 5678 - MainThread - worker-1 - WARNING - retrying`: LevelWarning,
+		`2003-07-08 16:49:45,896 django.server INFO "GET /api HTTP/1.1" 500 145 Error`:                           LevelInfo,
+
+		// celery
+		`[2024-01-15 10:20:30,123: ERROR/ForkPoolWorker-2] Task tasks.send[f3b1] raised unexpected: ValueError()`: LevelError,
+		`[2024-01-15 10:20:30,123: WARNING/MainProcess] consumer: Connection to broker lost.`:                     LevelWarning,
+		`[2024-01-15 10:20:30,123: INFO/MainProcess] Task tasks.send[f3b1] succeeded in 0.01s: None`:              LevelInfo,
+
+		// gunicorn
+		`[2024-01-15 10:20:30 +0000] [7] [CRITICAL] WORKER TIMEOUT (pid:8)`:          LevelCritical,
+		`[2024-01-15 10:20:30 +0000] [8] [ERROR] Exception in worker process`:        LevelError,
+		`[2024-01-15 10:20:30 +0000] [1] [INFO] Booting worker with pid: 8`:          LevelInfo,
+		`[2024-01-15 10:20:30 +0000] [1] [WARNING] Worker with pid 8 was terminated`: LevelWarning,
+
+		// not python
+		`Error: Cannot find module 'express'`:                  LevelError,
+		`2003-07-08 16:49:45,896 starting the server on :8080`: LevelUnknown,
+	} {
+		assert.Equal(t, level, GuessLevel(line), line)
+	}
+}
+
+func TestGuessLevelDatabases(t *testing.T) {
+	for line, level := range map[string]Level{
+		// postgres
+		`2024-01-15 10:20:30.123 UTC [1234] LOG:  checkpoint starting: time`:                                   LevelInfo,
+		`2024-01-15 10:20:30.123 UTC [1234] app@orders ERROR:  duplicate key value violates unique constraint`: LevelError,
+		`2024-01-15 10:20:30.123 UTC [1234] app@orders FATAL:  password authentication failed for user "app"`:  LevelCritical,
+		`2024-01-15 10:20:30.123 UTC [1] PANIC:  could not locate a valid checkpoint record`:                   LevelCritical,
+		`2024-01-15 10:20:30.123 UTC [1234] WARNING:  there is no transaction in progress`:                     LevelWarning,
+		`2024-01-15 10:20:30.123 UTC [1234] DEBUG2:  checkpointer updated shared memory configuration values`:  LevelDebug,
+		`LOG:  database system is ready to accept connections`:                                                 LevelInfo,
+
+		// mysql
+		`2024-01-15T10:20:30.123456Z 0 [System] [MY-010116] [Server] /usr/sbin/mysqld (mysqld 8.0.35) starting as process 1`:                 LevelInfo,
+		`2024-01-15T10:20:30.123456Z 0 [ERROR] [MY-010119] [Server] Aborting`:                                                                LevelError,
+		`2024-01-15T10:20:30.123456Z 0 [Warning] [MY-010068] [Server] CA certificate ca.pem is self signed.`:                                 LevelWarning,
+		`2024-01-15T10:20:30.123456Z 12 [Note] Aborted connection 12 to db: 'shop' user: 'app' (Got an error reading communication packets)`: LevelInfo,
+		`2024-01-15 10:20:30 0 [Note] InnoDB: Starting shutdown...`:                                                                          LevelInfo,
+
+		// mongodb
+		`{"t":{"$date":"2024-01-15T10:20:30.123+00:00"},"s":"I",  "c":"NETWORK",  "id":22943,   "ctx":"listener","msg":"Connection accepted"}`:                LevelInfo,
+		`{"t":{"$date":"2024-01-15T10:20:30.123+00:00"},"s":"E",  "c":"STORAGE",  "id":22435,   "ctx":"initandlisten","msg":"WiredTiger error message"}`:      LevelError,
+		`{"t":{"$date":"2024-01-15T10:20:30.123+00:00"},"s":"W",  "c":"CONTROL",  "id":22120,   "ctx":"initandlisten","msg":"Access control is not enabled"}`: LevelWarning,
+		`{"t":{"$date":"2024-01-15T10:20:30.123+00:00"},"s":"F",  "c":"-",        "id":23089,   "ctx":"conn1","msg":"Fatal assertion"}`:                       LevelCritical,
+		`{"t":{"$date":"2024-01-15T10:20:30.123+00:00"},"s":"D2", "c":"COMMAND",  "id":21965,   "ctx":"conn1","msg":"About to run the command"}`:              LevelDebug,
+	} {
+		assert.Equal(t, level, GuessLevel(line), line)
+	}
+}
+
+func TestPostgresSeverity(t *testing.T) {
+	for line, severity := range map[string]string{
+		`2024-01-15 10:20:30.123 UTC [1234] app@orders ERROR:  duplicate key value violates unique constraint`: "ERROR",
+		`2024-01-15 10:20:30 UTC [1234]: [3-1] user=app,db=orders DETAIL:  Key (id)=(42) already exists.`:      "DETAIL",
+		`[1234] HINT:  Perhaps you meant to reference the table "users".`:                                      "HINT",
+		`STATEMENT:  SELECT * FROM users`:                                                              "STATEMENT",
+		`validation failed DETAIL:  field "name" is required`:                                          "",
+		`2024-01-15 10:20:30 worker-1 retried the job 3 times and gave up with HINT:  check the queue`: "",
+	} {
+		assert.Equal(t, severity, postgresSeverity(line), line)
+	}
+}
+
+func TestLevelFromString(t *testing.T) {
+	for s, level := range map[string]Level{
+		"TRACE": LevelTrace, "trc": LevelTrace,
+		"debug": LevelDebug, "dbg": LevelDebug,
+		"info": LevelInfo, "Information": LevelInfo,
+		"notice": LevelNotice,
+		"warn":   LevelWarning, "WARNING": LevelWarning, "wrn": LevelWarning,
+		"err": LevelError, "error": LevelError,
+		"crit": LevelCritical, "emerg": LevelCritical, "alert": LevelCritical,
+		"fatal": LevelFatal, "panic": LevelFatal, "dpanic": LevelFatal,
+		"verbose": LevelUnknown, "": LevelUnknown,
+	} {
+		assert.Equal(t, level, LevelFromString(s), s)
+	}
+}
+
+func TestLevelSeverityNumber(t *testing.T) {
+	levels := []Level{LevelTrace, LevelDebug, LevelInfo, LevelNotice, LevelWarning, LevelError, LevelFatal, LevelCritical}
+	for i, l := range levels {
+		assert.Equal(t, l, LevelFromSeverityNumber(l.SeverityNumber()), l.String())
+		assert.Equal(t, l, LevelFromString(l.String()))
+		if i > 0 {
+			assert.Greater(t, l.SeverityNumber(), levels[i-1].SeverityNumber())
+		}
+	}
+	assert.Equal(t, 0, LevelUnknown.SeverityNumber())
+	assert.Equal(t, LevelUnknown, LevelFromSeverityNumber(0))
+	assert.Equal(t, LevelWarning, LevelFromSeverityNumber(15))
+	assert.Equal(t, LevelCritical, LevelFromSeverityNumber(24))
+
+	assert.Equal(t, LevelDebug, GuessLevel("[06:23:18 TRC] message"))
+	assert.Equal(t, LevelInfo, GuessLevel("[Mon Jan 15 10:20:30 2024] [notice] Apache/2.4.58 configured -- resuming normal operations"))
+	assert.Equal(t, LevelUnknown, GuessLevel("Traceback (most recent call last):"))
+	assert.Equal(t, LevelInfo, LevelByPriority("5"))
+
+	// the finer levels are opt-in
+	g := NewLevelGuesser()
+	g.FineLevels = true
+	assert.Equal(t, LevelTrace, g.Guess("2024-01-15 10:20:30.123 TRACE o.h.type.descriptor.sql.BasicBinder - binding parameter [1]"))
+	assert.Equal(t, LevelTrace, g.Guess("[06:23:18 TRC] message"))
+	assert.Equal(t, LevelNotice, g.Guess("[Mon Jan 15 10:20:30 2024] [notice] Apache/2.4.58 configured -- resuming normal operations"))
+	assert.Equal(t, LevelFatal, g.Guess("[06:23:18 FTL] message"))
+	assert.Equal(t, LevelFatal, g.Guess(`F0825 185142 test.cc:22] Check failed: write(1, NULL, 2) >= 0 Write NULL failed: Bad address [14]`))
+}
@@ -0,0 +1,130 @@
+package logparser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+var (
+	logfmtMessageKeys   = []string{"msg", "message"}
+	logfmtLevelKeys     = []string{"level", "lvl"}
+	logfmtTimestampKeys = []string{"time", "ts"}
+)
+
+type LogfmtDecoder struct{}
+
+func (d LogfmtDecoder) Decode(src string) (string, error) {
+	entry, err := d.DecodeEntry(LogEntry{Content: src})
+	if err != nil {
+		return "", err
+	}
+	return entry.Content, nil
+}
+
+func (d LogfmtDecoder) DecodeEntry(entry LogEntry) (LogEntry, error) {
+	pairs, err := parseLogfmt(entry.Content)
+	if err != nil {
+		return entry, fmt.Errorf(`failed to parse logfmt entry "%s": %s`, entry.Content, err)
+	}
+	fields := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		fields[p.key] = p.value
+	}
+	if k := firstKey(fields, logfmtMessageKeys); k != "" {
+		entry.Content = fields[k]
+		delete(fields, k)
+	}
+	if k := firstKey(fields, logfmtLevelKeys); k != "" {
+		if l := LevelFromString(fields[k]); l != LevelUnknown {
+			entry.Level = l
+			delete(fields, k)
+		}
+	}
+	if k := firstKey(fields, logfmtTimestampKeys); k != "" {
+		if ts, ok := parseTimestamp(fields[k]); ok {
+			entry.Timestamp = ts
+			delete(fields, k)
+		}
+	}
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry, nil
+}
+
+type logfmtPair struct {
+	key   string
+	value string
+}
+
+func parseLogfmt(s string) ([]logfmtPair, error) {
+	var res []logfmtPair
+	var withValue bool
+	i := 0
+	for {
+		for i < len(s) && isLogfmtSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		start := i
+		for i < len(s) && !isLogfmtSpace(s[i]) && s[i] != '=' {
+			if s[i] == '"' {
+				return nil, fmt.Errorf("unexpected quote in key at %d", i)
+			}
+			i++
+		}
+		key := s[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("empty key at %d", i)
+		}
+		if i >= len(s) || s[i] != '=' {
+			res = append(res, logfmtPair{key: key})
+			continue
+		}
+		i++
+		withValue = true
+		if i < len(s) && s[i] == '"' {
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated quoted value for key %s", key)
+			}
+			value, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				value = s[i+1 : j]
+			}
+			res = append(res, logfmtPair{key: key, value: value})
+			i = j + 1
+			continue
+		}
+		start = i
+		for i < len(s) && !isLogfmtSpace(s[i]) {
+			i++
+		}
+		res = append(res, logfmtPair{key: key, value: s[start:i]})
+	}
+	if !withValue {
+		return nil, fmt.Errorf("no key=value pairs found")
+	}
+	return res, nil
+}
+
+func isLogfmtSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+func firstKey(fields map[string]string, keys []string) string {
+	for _, k := range keys {
+		if _, ok := fields[k]; ok {
+			return k
+		}
+	}
+	return ""
+}
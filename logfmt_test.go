@@ -0,0 +1,50 @@
+package logparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogfmtDecoder(t *testing.T) {
+	d := LogfmtDecoder{}
+
+	entry, err := d.DecodeEntry(LogEntry{Content: `time=2024-01-15T10:20:30.123+01:00 level=ERROR msg="failed to connect to \"db\"" addr=10.0.0.1:5432 attempt=3`})
+	require.NoError(t, err)
+	assert.Equal(t, `failed to connect to "db"`, entry.Content)
+	assert.Equal(t, LevelError, entry.Level)
+	assert.Equal(t, time.Date(2024, 1, 15, 9, 20, 30, 123000000, time.UTC), entry.Timestamp.UTC())
+	assert.Equal(t, map[string]string{"addr": "10.0.0.1:5432", "attempt": "3"}, entry.Fields)
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `ts=1705314030.5 caller=main.go:42 lvl=warn message="disk usage high" path=/var empty= flag`})
+	require.NoError(t, err)
+	assert.Equal(t, "disk usage high", entry.Content)
+	assert.Equal(t, LevelWarning, entry.Level)
+	assert.Equal(t, time.Unix(1705314030, 500000000), entry.Timestamp)
+	assert.Equal(t, map[string]string{"caller": "main.go:42", "path": "/var", "empty": "", "flag": ""}, entry.Fields)
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `at=info method=GET path="/" host=app.herokuapp.com status=200`, Level: LevelUnknown})
+	require.NoError(t, err)
+	assert.Equal(t, `at=info method=GET path="/" host=app.herokuapp.com status=200`, entry.Content)
+	assert.Equal(t, LevelUnknown, entry.Level)
+	assert.Equal(t, "200", entry.Fields["status"])
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `level=verbose msg=started`})
+	require.NoError(t, err)
+	assert.Equal(t, "started", entry.Content)
+	assert.Equal(t, LevelUnknown, entry.Level)
+	assert.Equal(t, map[string]string{"level": "verbose"}, entry.Fields)
+
+	content, err := d.Decode(`level=info msg="multi\nline"`)
+	require.NoError(t, err)
+	assert.Equal(t, "multi\nline", content)
+
+	_, err = d.Decode(`plain text line`)
+	assert.Error(t, err)
+	_, err = d.Decode(`msg="unterminated`)
+	assert.Error(t, err)
+	_, err = d.Decode(`=value`)
+	assert.Error(t, err)
+}
@@ -12,10 +12,25 @@ var (
 	multilineCollectorLimit = 64 * 1024
 )
 
+const (
+	bracketsMaxDepth = 32
+	bracketsMaxLines = 100
+)
+
 type Message struct {
 	Timestamp time.Time
 	Content   string
 	Level     Level
+	Fields    map[string]string
+	Pattern   string
+	Source    string
+
+	LevelSource LevelSource
+
+	// Lines and Size describe the message as received, before it was truncated to the collector limits
+	Lines     int
+	Size      int
+	Truncated bool
 }
 
 type MultilineCollector struct {
@@ -23,54 +38,123 @@ type MultilineCollector struct {
 
 	timeout time.Duration
 	limit   int
+	opts    options
+
+	ts          time.Time
+	level       Level
+	levelSource LevelSource
+	fields      map[string]string
+	pattern     string
+	source      string
+	lines       []string
+	size        int
+
+	receivedLines int
+	receivedSize  int
+	truncated     bool
 
-	ts    time.Time
-	level Level
-	lines []string
-	size  int
+	lock             sync.Mutex
+	closed           bool
+	done             chan struct{}
+	firstReceiveTime time.Time
+	lastReceiveTime  time.Time
 
-	lock            sync.Mutex
-	closed          bool
-	lastReceiveTime time.Time
+	drops *dropCounter
 
 	isFirstLineContainsTimestamp bool
 	pythonTraceback              bool
 	pythonTracebackExpected      bool
+	jvmStackTrace                bool
+	goPanic                      bool
+	nodeUncaughtException        bool
+	postgres                     bool
+	bracketsDepth                int
+	bracketsLines                int
 }
 
-func NewMultilineCollector(ctx context.Context, timeout time.Duration, limit int) *MultilineCollector {
+func NewMultilineCollector(ctx context.Context, timeout time.Duration, limit int, opts ...Option) *MultilineCollector {
+	return newMultilineCollector(ctx, timeout, limit, newOptions(opts))
+}
+
+func newMultilineCollector(ctx context.Context, timeout time.Duration, limit int, opts options) *MultilineCollector {
 	m := &MultilineCollector{
 		timeout:  timeout,
 		limit:    limit,
+		opts:     opts,
 		Messages: make(chan Message, 1),
+		drops:    newDropCounter(),
+		done:     make(chan struct{}),
 	}
 	go m.dispatch(ctx)
 	return m
 }
 
 func (m *MultilineCollector) dispatch(ctx context.Context) {
-	ticker := time.NewTicker(m.timeout)
+	interval := m.timeout
+	if maxWait := m.maxWait(); maxWait > 0 && maxWait < interval {
+		interval = maxWait
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
-	defer close(m.Messages)
 
 	for {
 		select {
 		case <-ctx.Done():
-			m.closed = true
-			return
-		case t := <-ticker.C:
 			m.lock.Lock()
-			if t.Sub(m.lastReceiveTime) > m.timeout {
-				m.flushMessage()
-			}
+			m.close()
 			m.lock.Unlock()
+			return
+		case <-m.done:
+			return
+		case t := <-ticker.C:
+			m.flushExpired(t)
 		}
 	}
 }
 
+func (m *MultilineCollector) maxWait() time.Duration {
+	if rule := m.opts.multilineRule; rule != nil {
+		return rule.MaxWait
+	}
+	return 0
+}
+
+// flushExpired sends the pending message if no lines have been received for the timeout
+// or if its first line has been received more than the MaxWait of the rule ago
+func (m *MultilineCollector) flushExpired(now time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	maxWait := m.maxWait()
+	if now.Sub(m.lastReceiveTime) > m.timeout || (maxWait > 0 && len(m.lines) > 0 && now.Sub(m.firstReceiveTime) > maxWait) {
+		m.flushMessage()
+	}
+}
+
+// Close sends the pending message and closes the Messages channel
+func (m *MultilineCollector) Close() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.flushMessage()
+	m.close()
+}
+
+func (m *MultilineCollector) close() {
+	if m.closed {
+		return
+	}
+	m.closed = true
+	close(m.Messages)
+	close(m.done)
+}
+
 func (m *MultilineCollector) Add(entry LogEntry) {
 	if !utf8.ValidString(entry.Content) {
-		return
+		content, ok := m.toValidUTF8(entry.Content)
+		if !ok {
+			m.drops.add(DropReasonInvalidUtf8, entry.Content)
+			return
+		}
+		entry.Content = content
 	}
 
 	m.lock.Lock()
@@ -83,29 +167,61 @@ func (m *MultilineCollector) Add(entry LogEntry) {
 		}
 		return
 	}
+	if rule := m.opts.multilineRule; rule != nil {
+		if rule.isNextMessage(entry.Content) {
+			m.flushMessage()
+		}
+		m.add(entry)
+		return
+	}
 	if m.isNextMessage(entry.Content) {
-		pythonTraceback := m.pythonTraceback
+		pythonTraceback, goPanic, nodeUncaughtException := m.pythonTraceback, m.goPanic, m.nodeUncaughtException
 		m.flushMessage()
-		m.pythonTraceback = pythonTraceback
+		m.pythonTraceback, m.goPanic, m.nodeUncaughtException = pythonTraceback, goPanic, nodeUncaughtException
 	}
 	m.add(entry)
 }
 
+func (m *MultilineCollector) toValidUTF8(s string) (string, bool) {
+	switch m.opts.utf8Policy {
+	case UTF8PolicyReplace:
+		return strings.ToValidUTF8(s, "\uFFFD"), true
+	case UTF8PolicyTranscode:
+		if m.opts.charset != nil {
+			if res, err := m.opts.charset.NewDecoder().String(s); err == nil && utf8.ValidString(res) {
+				return res, true
+			}
+		}
+		return strings.ToValidUTF8(s, "\uFFFD"), true
+	}
+	return "", false
+}
+
 func (m *MultilineCollector) add(entry LogEntry) {
+	m.receivedLines++
+	m.receivedSize += len(entry.Content) + 1
 	remaining := m.limit - m.size
-	if remaining <= 0 {
+	if remaining <= 0 || m.maxLinesExceeded() {
+		m.drops.add(DropReasonTruncated, entry.Content)
+		m.truncated = true
+		m.bracketsDepth = 0
 		return
 	}
 	if len(m.lines) == 0 {
+		m.firstReceiveTime = time.Now()
 		m.ts = entry.Timestamp
-		m.level = GuessLevel(entry.Content)
-		if m.level == LevelUnknown && entry.Level != LevelUnknown {
-			m.level = entry.Level
-		}
+		m.fields = entry.Fields
+		m.pattern = entry.Pattern
+		m.source = entry.Source
+		m.level, m.levelSource = resolveLevel(m.opts.levelPolicy, m.opts.levelGuesser, entry)
 		m.isFirstLineContainsTimestamp = containsTimestamp(entry.Content)
+		severity := postgresSeverity(entry.Content)
+		m.postgres = severity != "" && !isPostgresDetail(severity)
 	}
 	content := entry.Content
 	if len(content) > remaining {
+		m.drops.add(DropReasonTruncated, entry.Content)
+		m.truncated = true
 		for remaining > 0 && !utf8.RuneStart(content[remaining]) {
 			remaining--
 		}
@@ -114,24 +230,132 @@ func (m *MultilineCollector) add(entry LogEntry) {
 		}
 		content = content[:remaining]
 	}
+	m.trackBrackets(content)
 	m.lines = append(m.lines, content)
 	m.size += len(content) + 1
 	m.lastReceiveTime = time.Now()
 }
 
+func (m *MultilineCollector) maxLinesExceeded() bool {
+	if rule := m.opts.multilineRule; rule != nil && rule.MaxLines > 0 && len(m.lines) >= rule.MaxLines {
+		return true
+	}
+	return m.opts.maxLinesPerMessage > 0 && len(m.lines) >= m.opts.maxLinesPerMessage
+}
+
 func (m *MultilineCollector) isNextMessage(l string) bool {
-	if l == "" || l == "}" || strings.HasPrefix(l, "\t") || strings.HasPrefix(l, "  ") {
+	if m.bracketsDepth > 0 {
+		if !m.isMessageHeader(l) {
+			return false
+		}
+		// the brackets of the previous message are unbalanced, e.g. "INFO payload: {" without the document
+		m.bracketsDepth = 0
+	}
+	if trimmed := strings.TrimRight(l, " \t"); trimmed == "{" || trimmed == "[" {
+		return false
+	}
+	if m.pythonTraceback {
+		return m.isNextMessageHeuristic(l)
+	}
+	switch getGoLineKind(l) {
+	case goLinePanic:
+		m.goPanic = true
+		return len(m.lines) > 0
+	case goLineGoroutine:
+		m.goPanic = true
+		return false
+	case goLineContinuation:
+		if m.goPanic {
+			return false
+		}
+	}
+	if isStackTraceContinuation(l) || m.postgres && isPostgresContinuation(l) {
 		return false
 	}
+	if isNodeErrorSource(l) {
+		m.nodeUncaughtException = true
+		return len(m.lines) > 0
+	}
+	if m.nodeUncaughtException && (len(m.lines) == 1 || isNodeError(l)) {
+		return false
+	}
+	switch getJvmLineKind(l) {
+	case jvmLineContinuation:
+		m.jvmStackTrace = true
+		return false
+	case jvmLineException:
+		// an exception right after a warning/error header belongs to it, but an exception after stack frames starts a new message
+		next := m.jvmStackTrace
+		if !next && m.level.SeverityNumber() < LevelWarning.SeverityNumber() {
+			next = m.isNextMessageHeuristic(l)
+		}
+		m.jvmStackTrace = false
+		return next
+	}
+	next := m.isNextMessageHeuristic(l)
+	if next {
+		m.jvmStackTrace = false
+		m.goPanic = false
+		m.nodeUncaughtException = false
+	}
+	return next
+}
 
-	if m.isFirstLineContainsTimestamp {
-		return containsTimestamp(l)
+// trackBrackets keeps a pretty-printed JSON document (or a YAML flow collection) in the current message until
+// its brackets are balanced. Tracking starts only at an opening bracket at the end of the first line
+// or at a line consisting of a single bracket, and stops if the document is too deep or too long,
+// or if the next message starts (see isMessageHeader).
+func (m *MultilineCollector) trackBrackets(l string) {
+	if m.bracketsDepth == 0 {
+		trimmed := strings.TrimRight(l, " \t")
+		switch {
+		case trimmed == "{" || trimmed == "[":
+		case len(m.lines) == 0 && (strings.HasSuffix(trimmed, "{") || strings.HasSuffix(trimmed, "[")):
+		default:
+			return
+		}
+		m.bracketsLines = 0
+	}
+	var quoted, escaped bool
+	for i := 0; i < len(l); i++ {
+		switch c := l[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '{' || c == '[':
+			m.bracketsDepth++
+		case c == '}' || c == ']':
+			m.bracketsDepth--
+		}
+	}
+	m.bracketsLines++
+	if m.bracketsDepth < 0 || m.bracketsDepth > bracketsMaxDepth || m.bracketsLines > bracketsMaxLines {
+		m.bracketsDepth = 0
 	}
+}
+
+// isMessageHeader reports whether a line inside a tracked document looks like the first line of the next message:
+// it is neither indented nor a JSON string, and it contains a timestamp or a level
+func (m *MultilineCollector) isMessageHeader(l string) bool {
+	if l == "" || l[0] == ' ' || l[0] == '\t' || l[0] == '"' {
+		return false
+	}
+	return containsTimestamp(l) || m.opts.levelGuesser.Guess(l) != LevelUnknown
+}
 
-	if strings.HasPrefix(l, "Caused by: ") {
+func (m *MultilineCollector) isNextMessageHeuristic(l string) bool {
+	if l == "" || l == "}" || strings.HasPrefix(l, "\t") || strings.HasPrefix(l, "  ") {
 		return false
 	}
 
+	if m.isFirstLineContainsTimestamp {
+		return containsTimestamp(l)
+	}
+
 	if strings.HasPrefix(l, "for call at") {
 		return false
 	}
@@ -165,9 +389,16 @@ func (m *MultilineCollector) flushMessage() {
 	}
 	content := strings.TrimSpace(strings.Join(m.lines, "\n"))
 	msg := Message{
-		Timestamp: m.ts,
-		Content:   content,
-		Level:     m.level,
+		Timestamp:   m.ts,
+		Content:     content,
+		Level:       m.level,
+		Fields:      m.fields,
+		Pattern:     m.pattern,
+		Source:      m.source,
+		LevelSource: m.levelSource,
+		Lines:       m.receivedLines,
+		Size:        m.receivedSize - 1,
+		Truncated:   m.truncated,
 	}
 	m.reset()
 	m.Messages <- msg
@@ -176,9 +407,22 @@ func (m *MultilineCollector) flushMessage() {
 func (m *MultilineCollector) reset() {
 	m.ts = time.Time{}
 	m.level = LevelUnknown
+	m.levelSource = LevelSourceNone
+	m.fields = nil
+	m.pattern = ""
+	m.source = ""
 	m.lines = m.lines[:0]
 	m.size = 0
+	m.receivedLines = 0
+	m.receivedSize = 0
+	m.truncated = false
 	m.isFirstLineContainsTimestamp = false
 	m.pythonTraceback = false
 	m.pythonTracebackExpected = false
+	m.jvmStackTrace = false
+	m.goPanic = false
+	m.nodeUncaughtException = false
+	m.postgres = false
+	m.bracketsDepth = 0
+	m.bracketsLines = 0
 }
@@ -0,0 +1,97 @@
+package logparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+)
+
+// MultilineRule replaces the built-in heuristics of MultilineCollector, similar to Filebeat's multiline options.
+// A line matching Start begins a new message. If Continuation is set, a line is appended to the current message
+// only if it matches Continuation, or only if it doesn't match Continuation if Negate is set.
+// Lines beyond MaxLines are dropped, and a message is flushed once MaxWait has passed since its first line.
+type MultilineRule struct {
+	Source       string
+	Start        *regexp.Regexp
+	Continuation *regexp.Regexp
+	Negate       bool
+	MaxLines     int
+	MaxWait      time.Duration
+}
+
+func (r *MultilineRule) isNextMessage(l string) bool {
+	if r.Start != nil && r.Start.MatchString(l) {
+		return true
+	}
+	if r.Continuation != nil {
+		return r.Continuation.MatchString(l) == r.Negate
+	}
+	return false
+}
+
+type MultilineRules []MultilineRule
+
+// Select returns the first rule whose Source glob matches the source, or nil to use the built-in heuristics.
+// A rule with an empty Source matches any source.
+func (rs MultilineRules) Select(source string) *MultilineRule {
+	for i := range rs {
+		if rs[i].Source == "" {
+			return &rs[i]
+		}
+		if ok, _ := path.Match(rs[i].Source, source); ok {
+			return &rs[i]
+		}
+	}
+	return nil
+}
+
+type multilineRuleJson struct {
+	Source       string `json:"source"`
+	Start        string `json:"start"`
+	Continuation string `json:"continuation"`
+	Negate       bool   `json:"negate"`
+	MaxLines     int    `json:"max_lines"`
+	MaxWait      string `json:"max_wait"`
+}
+
+// ParseMultilineRules parses a JSON array of rules:
+//
+//	[{"source": "/var/log/app/*.log", "start": "^\\d{4}-\\d{2}-\\d{2}", "max_lines": 500, "max_wait": "5s"}]
+func ParseMultilineRules(data []byte) (MultilineRules, error) {
+	var items []multilineRuleJson
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal multiline rules: %w", err)
+	}
+	rules := make(MultilineRules, 0, len(items))
+	for i, item := range items {
+		r := MultilineRule{Source: item.Source, Negate: item.Negate, MaxLines: item.MaxLines}
+		if item.Start == "" && item.Continuation == "" {
+			return nil, fmt.Errorf("multiline rule #%d: either start or continuation must be set", i)
+		}
+		var err error
+		if item.Start != "" {
+			if r.Start, err = regexp.Compile(item.Start); err != nil {
+				return nil, fmt.Errorf("multiline rule #%d: invalid start regexp: %w", i, err)
+			}
+		}
+		if item.Continuation != "" {
+			if r.Continuation, err = regexp.Compile(item.Continuation); err != nil {
+				return nil, fmt.Errorf("multiline rule #%d: invalid continuation regexp: %w", i, err)
+			}
+		}
+		if item.MaxWait != "" {
+			if r.MaxWait, err = time.ParseDuration(item.MaxWait); err != nil {
+				return nil, fmt.Errorf("multiline rule #%d: invalid max_wait: %w", i, err)
+			}
+		}
+		if item.Source != "" {
+			if _, err = path.Match(item.Source, ""); err != nil {
+				return nil, fmt.Errorf("multiline rule #%d: invalid source: %w", i, err)
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
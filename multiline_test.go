@@ -2,6 +2,7 @@ package logparser
 
 import (
 	"context"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -298,6 +299,78 @@ Caused by: java.sql.SQLException: Violation of unique constraint MY_ENTITY_UK_1:
 	assert.Equal(t, data, msgs[0].Content)
 }
 
+func TestMultilineCollectorJavaFrameworks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	defer cancel()
+
+	// Spring Boot: the exception message contains a timestamp
+	spring := `2023-10-04 14:27:35.249 ERROR 1 --- [nio-8080-exec-1] o.a.c.c.C.[.[.[/].[dispatcherServlet]    : Servlet.service() for servlet [dispatcherServlet] in context with path [] threw exception [Request processing failed; nested exception is java.lang.IllegalStateException: Job failed at 2023-10-04 14:27:35] with root cause
+
+java.lang.IllegalStateException: Job failed at 2023-10-04 14:27:35
+	at com.example.demo.JobService.run(JobService.kt:42) ~[classes/:na]
+	at com.example.demo.JobController.start(JobController.kt:18) ~[classes/:na]
+	at java.base/jdk.internal.reflect.NativeMethodAccessorImpl.invoke0(Native Method) ~[na:na]
+	at org.springframework.web.servlet.FrameworkServlet.processRequest(FrameworkServlet.java:1006) ~[spring-webmvc-5.3.23.jar:5.3.23]
+	... 45 common frames omitted`
+	next := `2023-10-04 14:27:36.001  INFO 1 --- [nio-8080-exec-2] c.e.demo.JobController                   : next`
+	msgs := writeByLine(m, spring+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, spring, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	tomcat := `04-Oct-2023 14:27:35.249 SEVERE [http-nio-8080-exec-1] org.apache.catalina.core.StandardWrapperValve.invoke Servlet.service() for servlet [jsp] threw exception
+java.lang.NullPointerException: Cannot invoke "String.length()" because "s" is null
+	at org.apache.jsp.index_jsp._jspService(index_jsp.java:120)
+	at org.apache.jasper.runtime.HttpJspBase.service(HttpJspBase.java:71)
+	at javax.servlet.http.HttpServlet.service(HttpServlet.java:764)
+	at java.base/java.lang.Thread.run(Thread.java:833)`
+	next = `04-Oct-2023 14:27:36.101 INFO [main] org.apache.catalina.startup.Catalina.start Server startup in [1234] milliseconds`
+	msgs = writeByLine(m, tomcat+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, tomcat, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	// Log4j2 without timestamps: %-5level %logger{36} - %msg%n%throwable
+	log4j := `ERROR com.example.OrderService - Failed to process order 42
+java.lang.IllegalStateException: Order 42 is already closed
+	at com.example.OrderService.process(OrderService.java:87) ~[app.jar:?]
+	at com.example.OrderService.lambda$submit$0(OrderService.java:55) ~[app.jar:?]
+	at java.util.concurrent.ThreadPoolExecutor.runWorker(ThreadPoolExecutor.java:1136) [?:?]
+	Suppressed: java.io.IOException: close failed
+		at com.example.Resource.close(Resource.java:12) ~[app.jar:?]
+		at com.example.OrderService.process(OrderService.java:90) ~[app.jar:?]
+Caused by: java.sql.SQLTransientConnectionException: HikariPool-1 - Connection is not available, request timed out after 30000ms.
+	at com.zaxxer.hikari.pool.HikariPool.createTimeoutException(HikariPool.java:696) ~[HikariCP-5.0.1.jar:?]
+	... 3 more`
+	next = `WARN  com.example.OrderService - Retrying order 42`
+	msgs = writeByLine(m, log4j+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, log4j, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	// Kotlin and Scala frames with the indentation stripped by a log shipper
+	stripped := `[main] ERROR Main - coroutine failed
+kotlinx.coroutines.JobCancellationException: Job was cancelled
+at kotlinx.coroutines.JobSupport.cancel(JobSupport.kt:1581)
+at com.example.MainKt$main$1.invokeSuspend(Main.kt:12)
+... 5 more
+Caused by: scala.MatchError: foo (of class java.lang.String)
+at com.example.Parser$.$anonfun$parse$1(Parser.scala:21)
+at scala.collection.immutable.List.foreach(List.scala:431)`
+	next = `[main] INFO Main - done`
+	msgs = writeByLine(m, stripped+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, stripped, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	// an unrelated info message is not merged with the following exception
+	msgs = writeByLine(m, "INFO started\n"+stripped, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "INFO started", msgs[0].Content)
+	assert.Equal(t, stripped, msgs[1].Content)
+}
+
 func TestMultilineCollectorJS(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
@@ -356,17 +429,230 @@ for call at
 	assert.Equal(t, data, msgs[0].Content)
 }
 
+func TestMultilineCollectorNodeUncaught(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	defer cancel()
+
+	header := `Server listening on port 3000`
+	data := `/app/server.js:12
+throw new Error('boom');
+^
+
+Error: boom
+    at Object.<anonymous> (/app/server.js:12:11)
+    at Module._compile (node:internal/modules/cjs/loader:1256:14)
+    at node:internal/main/run_main_module:23:47
+
+Node.js v18.17.0`
+	msgs := writeByLine(m, header+"\n"+data, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, header, msgs[0].Content)
+	assert.Equal(t, data, msgs[1].Content)
+
+	data = `file:///app/index.mjs:4
+    const x = obj.prop.value;
+                       ^
+
+TypeError: Cannot read properties of undefined (reading 'value')
+    at file:///app/index.mjs:4:24
+    at ModuleJob.run (node:internal/modules/esm/module_job:194:25)
+
+Node.js v20.5.1`
+	next := `Server listening on port 3000`
+	msgs = writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+}
+
+func TestMultilineCollectorRuby(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	defer cancel()
+
+	data := "/app/lib/worker.rb:10:in `perform': undefined method `name' for nil:NilClass (NoMethodError)\n" +
+		"\tfrom /app/lib/worker.rb:5:in `block in run'\n" +
+		"\tfrom /app/lib/worker.rb:4:in `each'\n" +
+		"\tfrom /app/main.rb:3:in `<main>'"
+	msgs := writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, data, msgs[0].Content)
+
+	// Ruby 3.4 quoting, indentation stripped
+	data = `/app/lib/worker.rb:10:in 'Worker#perform': undefined method 'name' for nil (NoMethodError)
+from /app/lib/worker.rb:5:in 'block in Worker#run'
+from /app/main.rb:3:in '<main>'`
+	next := `I, [2024-02-15T12:33:07.230967 #1]  INFO -- : started`
+	msgs = writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+}
+
+func TestMultilineCollectorDotNet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	defer cancel()
+
+	data := `Unhandled exception. System.AggregateException: One or more errors occurred. (Sequence contains no elements)
+ ---> System.InvalidOperationException: Sequence contains no elements
+   at System.Linq.ThrowHelper.ThrowNoElementsException()
+   at System.Linq.Enumerable.First[TSource](IEnumerable` + "`" + `1 source)
+   at Program.<Main>$(String[] args) in /src/Program.cs:line 5
+   --- End of inner exception stack trace ---
+   at System.Threading.Tasks.Task.ThrowIfExceptional(Boolean includeTaskCanceledExceptions)
+   at Program.<Main>(String[] args)`
+	msgs := writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, data, msgs[0].Content)
+
+	data = `[12:33:07 ERR] HTTP GET /orders responded 500 in 12.3456 ms
+System.Net.Http.HttpRequestException: Connection refused (orders:80)
+---> System.Net.Sockets.SocketException (111): Connection refused
+at System.Net.Http.HttpConnectionPool.ConnectToTcpHostAsync(String host, Int32 port, HttpRequestMessage initialRequest, Boolean async, CancellationToken cancellationToken)
+--- End of inner exception stack trace ---
+at System.Net.Http.HttpConnectionPool.ConnectToTcpHostAsync(String host, Int32 port, HttpRequestMessage initialRequest, Boolean async, CancellationToken cancellationToken)
+--- End of stack trace from previous location ---
+at Frontend.Controllers.OrdersController.Get() in /src/Controllers/OrdersController.cs:line 21`
+	next := `[12:33:08 INF] HTTP GET /health responded 200 in 0.1234 ms`
+	msgs = writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+}
+
 func TestMultilineCollectorGO(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
 	defer cancel()
 
-	// TODO: `panic` without timestamp in the first line
+	data := `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a5b2c]
 
-	data := ``
+goroutine 1 [running]:
+main.(*Server).handle(0x0, {0x5c1e20, 0xc000010250})
+	/app/server.go:42 +0x2c
+main.main()
+	/app/main.go:15 +0x65
+exit status 2`
 	msgs := writeByLine(m, data, time.Unix(0, 0))
 	require.Len(t, msgs, 1)
 	assert.Equal(t, data, msgs[0].Content)
+
+	// a panic without a timestamp after a log message with a timestamp
+	header := `2024/02/15 12:33:07 starting server on :8080`
+	data = `panic: sync: negative WaitGroup counter [recovered]
+	panic: sync: negative WaitGroup counter
+
+goroutine 18 gp=0xc000102380 m=4 mp=0xc000080008 [running]:
+panic({0x4b1ea0?, 0x5a3b90?})
+	/usr/local/go/src/runtime/panic.go:779 +0x158
+sync.(*WaitGroup).Add(0xc00001c0f0?, 0xc00001c0f0?)
+	/usr/local/go/src/sync/waitgroup.go:64 +0x1f6
+main.worker(0x0?)
+	/app/worker.go:27 +0x85
+created by main.main in goroutine 1
+	/app/main.go:20 +0x2b
+
+goroutine 1 [semacquire]:
+sync.runtime_Semacquire(0xc00001c0f8?)
+	/usr/local/go/src/runtime/sema.go:62 +0x25
+...additional frames elided...`
+	msgs = writeByLine(m, header+"\n"+data, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, header, msgs[0].Content)
+	assert.Equal(t, data, msgs[1].Content)
+
+	data = `fatal error: concurrent map writes
+
+goroutine 7 [running]:
+main.(*Cache).Set(...)
+	/app/cache.go:31
+main.handler({0x5d1e40, 0xc0000a8000}, 0xc0000b4000)
+	/app/main.go:52 +0x9d
+net/http.HandlerFunc.ServeHTTP(0xc000012345?, {0x5d1e40?, 0xc0000a8000?}, 0x0?)
+	/usr/local/go/src/net/http/server.go:2166 +0x29
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/local/go/src/net/http/server.go:3285 +0x4b4`
+	next := `2024/02/15 12:33:08 starting server on :8080`
+	msgs = writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	data = `SIGQUIT: quit
+PC=0x46d2a1 m=0 sigcode=0
+
+goroutine 0 gp=0x6eb2c0 m=0 mp=0x6ebe80 [idle]:
+runtime.futex(0x6ebfc0, 0x80, 0x0, 0x0, 0x0, 0x0)
+	/usr/local/go/src/runtime/sys_linux_amd64.s:557 +0x21
+
+goroutine 1 [chan receive, 5 minutes]:
+main.main()
+	/app/main.go:25 +0x1c5
+
+rax    0xca
+rbx    0x0
+rip    0x46d2a1
+rflags 0x286
+cs     0x33`
+	msgs = writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, data, msgs[0].Content)
+
+	// debug.Stack() printed after a log message
+	data = `2024/02/15 12:33:07 recovered: boom
+goroutine 5 [running]:
+runtime/debug.Stack()
+	/usr/local/go/src/runtime/debug/stack.go:24 +0x5e
+main.safe.func1()
+	/app/main.go:11 +0x45`
+	msgs = writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, data, msgs[0].Content)
+}
+
+func TestGoPanicPattern(t *testing.T) {
+	p1 := goPanicPattern(`panic: runtime error: index out of range [5] with length 3
+
+goroutine 1 [running]:
+main.lookup({0xc000012345, 0x3, 0x3}, 0x5)
+	/app/main.go:12 +0x1d
+main.handler(0xc0000a8000)
+	/app/main.go:30 +0x3a
+main.main()
+	/app/main.go:40 +0x25
+
+goroutine 7 [IO wait]:
+internal/poll.runtime_pollWait(0x7f, 0x72)
+	/usr/local/go/src/runtime/netpoll.go:345 +0x85`)
+	require.NotNil(t, p1)
+	assert.Equal(t, "panic runtime error index out of range with length main.lookup main.handler main.main", p1.String())
+
+	p2 := goPanicPattern(`panic: runtime error: index out of range [7] with length 2
+
+goroutine 42 [running]:
+main.lookup({0xc000099999, 0x2, 0x2}, 0x7)
+	/app/main.go:12 +0x1d
+main.handler(0xc0000b8000)
+	/app/main.go:30 +0x3a
+main.main()
+	/app/main.go:40 +0x25`)
+	assert.Equal(t, p1.Hash(), p2.Hash())
+
+	p3 := goPanicPattern(`panic: boom
+
+goroutine 1 [running]:
+panic({0x4b1ea0?, 0x5a3b90?})
+	/usr/local/go/src/runtime/panic.go:779 +0x158
+runtime.throw({0x4b1ea0?, 0x5a3b90?})
+	/usr/local/go/src/runtime/panic.go:1023 +0x5c
+main.(*Server).handle(0x0, {0x5c1e20, 0xc000010250})
+	/app/server.go:42 +0x2c`)
+	assert.Equal(t, "panic boom main.(*Server).handle", p3.String())
+
+	assert.Nil(t, goPanicPattern(`ERROR something failed`))
 }
 func TestMultilineCollectorLimit(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -391,3 +677,282 @@ func TestMultilineCollectorLimit(t *testing.T) {
 	assert.Equal(t, 97, len(msgs[0].Content))
 	assert.True(t, utf8.ValidString(msgs[0].Content))
 }
+
+func TestMultilineCollectorDrops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, 30)
+	defer cancel()
+
+	data := "I0215 12:33:07.230967 foo\n\xffbar\nbaz baz baz\nqux"
+	msgs := writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "I0215 12:33:07.230967 foo\nbaz", msgs[0].Content)
+	assert.True(t, msgs[0].Truncated)
+	assert.Equal(t, 3, msgs[0].Lines)
+	assert.Equal(t, 41, msgs[0].Size)
+
+	stats := Stats{Dropped: map[DropReason]int{}, Samples: map[DropReason][]string{}}
+	m.drops.addTo(&stats)
+	assert.Equal(t, map[DropReason]int{DropReasonInvalidUtf8: 1, DropReasonTruncated: 2}, stats.Dropped)
+	assert.Equal(t, map[DropReason][]string{
+		DropReasonInvalidUtf8: {"�bar"},
+		DropReasonTruncated:   {"baz baz baz", "qux"},
+	}, stats.Samples)
+}
+
+func TestMultilineCollectorMaxLines(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithMaxLinesPerMessage(2))
+	defer cancel()
+
+	data := `2020-03-20 08:48:57,067 ERROR failed
+  line 1
+  line 2
+  line 3
+2020-03-20 08:48:58,067 ERROR failed
+  line 1`
+	msgs := writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "2020-03-20 08:48:57,067 ERROR failed\n  line 1", msgs[0].Content)
+	assert.True(t, msgs[0].Truncated)
+	assert.Equal(t, 4, msgs[0].Lines)
+	assert.Equal(t, 63, msgs[0].Size)
+	assert.Equal(t, "2020-03-20 08:48:58,067 ERROR failed\n  line 1", msgs[1].Content)
+	assert.False(t, msgs[1].Truncated)
+	assert.Equal(t, 2, msgs[1].Lines)
+	assert.Equal(t, len(msgs[1].Content), msgs[1].Size)
+}
+
+func TestMultilineCollectorPostgres(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	defer cancel()
+
+	data := `2024-01-15 10:20:30.123 UTC [1234] app@orders ERROR:  duplicate key value violates unique constraint "orders_pkey"
+2024-01-15 10:20:30.123 UTC [1234] app@orders DETAIL:  Key (id)=(42) already exists.
+2024-01-15 10:20:30.123 UTC [1234] app@orders STATEMENT:  INSERT INTO orders (id) VALUES (42)`
+	next := `2024-01-15 10:20:31.123 UTC [1234] app@orders ERROR:  relation "user" does not exist at character 15
+2024-01-15 10:20:31.123 UTC [1234] app@orders HINT:  Perhaps you meant to reference the table "users".`
+	msgs := writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, LevelError, msgs[0].Level)
+	assert.Equal(t, next, msgs[1].Content)
+}
+
+func TestMultilineCollectorPostgresDetailInOtherLogs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	defer cancel()
+
+	data := `2024-01-15 10:20:30 INFO migration finished
+2024-01-15 10:20:31 [42] DETAIL:  3 tables altered`
+	msgs := writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "2024-01-15 10:20:30 INFO migration finished", msgs[0].Content)
+	assert.Equal(t, "2024-01-15 10:20:31 [42] DETAIL:  3 tables altered", msgs[1].Content)
+}
+
+func TestMultilineCollectorInvalidUTF8(t *testing.T) {
+	latin1 := "I0215 12:33:07.230967 caf\xe9 na\xefve"
+	sjis := "I0215 12:33:07.230967 \x83\x65\x83\x58\x83\x67"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	msgs := writeByLine(m, latin1, time.Unix(0, 0))
+	require.Len(t, msgs, 0)
+
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithUTF8Policy(UTF8PolicyReplace, nil))
+	msgs = writeByLine(m, latin1, time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "I0215 12:33:07.230967 caf� na�ve", msgs[0].Content)
+
+	charset, err := Charset("ISO-8859-1")
+	require.NoError(t, err)
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithUTF8Policy(UTF8PolicyTranscode, charset))
+	msgs = writeByLine(m, latin1+"\nI0215 12:33:08.230967 déjà vu", time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "I0215 12:33:07.230967 café naïve", msgs[0].Content)
+	assert.Equal(t, "I0215 12:33:08.230967 déjà vu", msgs[1].Content)
+
+	charset, err = Charset("windows-1252")
+	require.NoError(t, err)
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithUTF8Policy(UTF8PolicyTranscode, charset))
+	msgs = writeByLine(m, "I0215 12:33:07.230967 \x93quoted\x94 \x80", time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "I0215 12:33:07.230967 “quoted” €", msgs[0].Content)
+
+	charset, err = Charset("Shift_JIS")
+	require.NoError(t, err)
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithUTF8Policy(UTF8PolicyTranscode, charset))
+	msgs = writeByLine(m, sjis, time.Unix(0, 0))
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "I0215 12:33:07.230967 テスト", msgs[0].Content)
+
+	_, err = Charset("no-such-charset")
+	assert.Error(t, err)
+}
+
+func TestMultilineCollectorRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := `[2024-02-15 12:33:07] first
+details: a
+[not a timestamp]
+[2024-02-15 12:33:08] second`
+
+	rule := &MultilineRule{Start: regexp.MustCompile(`^\[\d{4}-\d{2}-\d{2} `)}
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithMultilineRule(rule))
+	msgs := writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "[2024-02-15 12:33:07] first\ndetails: a\n[not a timestamp]", msgs[0].Content)
+	assert.Equal(t, "[2024-02-15 12:33:08] second", msgs[1].Content)
+
+	// Filebeat's `pattern: '^\[', negate: true, match: after`
+	rule = &MultilineRule{Continuation: regexp.MustCompile(`^\[`), Negate: true}
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithMultilineRule(rule))
+	msgs = writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "[2024-02-15 12:33:07] first\ndetails: a", msgs[0].Content)
+	assert.Equal(t, "[not a timestamp]", msgs[1].Content)
+
+	rule = &MultilineRule{Start: regexp.MustCompile(`^\S`), MaxLines: 2}
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithMultilineRule(rule))
+	msgs = writeByLine(m, "first\n one\n two\n three\nsecond", time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "first\n one", msgs[0].Content)
+	assert.Equal(t, "second", msgs[1].Content)
+
+	// Negate applies to Continuation only
+	rule = &MultilineRule{Start: regexp.MustCompile(`^\[\d{4}-\d{2}-\d{2} `), Continuation: regexp.MustCompile(`^\[`), Negate: true}
+	m = NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit, WithMultilineRule(rule))
+	msgs = writeByLine(m, data, time.Unix(0, 0))
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "[2024-02-15 12:33:07] first\ndetails: a", msgs[0].Content)
+	assert.Equal(t, "[not a timestamp]", msgs[1].Content)
+	assert.Equal(t, "[2024-02-15 12:33:08] second", msgs[2].Content)
+
+	// the ticker doesn't fire during the test, the expiration is checked explicitly
+	rule = &MultilineRule{Start: regexp.MustCompile(`^\S`), MaxWait: time.Hour}
+	m = NewMultilineCollector(ctx, 2*time.Hour, multilineCollectorLimit, WithMultilineRule(rule))
+	m.Add(LogEntry{Content: "first"})
+	m.Add(LogEntry{Content: " continuation"})
+	now := time.Now()
+	m.flushExpired(now.Add(30 * time.Minute))
+	assert.Empty(t, m.Messages)
+	m.Add(LogEntry{Content: " continuation"})
+	m.flushExpired(now.Add(90 * time.Minute))
+	require.Len(t, m.Messages, 1)
+	assert.Equal(t, "first\n continuation\n continuation", (<-m.Messages).Content)
+}
+
+func TestMultilineRules(t *testing.T) {
+	rules, err := ParseMultilineRules([]byte(`[
+		{"source": "/var/log/app/*.log", "start": "^\\d{4}-", "max_lines": 500, "max_wait": "5s"},
+		{"continuation": "^\\s", "negate": true}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	r := rules.Select("/var/log/app/api.log")
+	require.NotNil(t, r)
+	assert.Equal(t, `^\d{4}-`, r.Start.String())
+	assert.Equal(t, 500, r.MaxLines)
+	assert.Equal(t, 5*time.Second, r.MaxWait)
+
+	r = rules.Select("stdin")
+	require.NotNil(t, r)
+	assert.Nil(t, r.Start)
+	assert.True(t, r.Negate)
+
+	assert.Nil(t, MultilineRules(rules[:1]).Select("stdin"))
+
+	_, err = ParseMultilineRules([]byte(`[{"source": "*"}]`))
+	assert.Error(t, err)
+	_, err = ParseMultilineRules([]byte(`[{"start": "("}]`))
+	assert.Error(t, err)
+	_, err = ParseMultilineRules([]byte(`[{"start": "^a", "max_wait": "soon"}]`))
+	assert.Error(t, err)
+}
+
+func TestMultilineCollectorPrettyPrinted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMultilineCollector(ctx, 10*time.Millisecond, multilineCollectorLimit)
+	defer cancel()
+
+	data := `INFO request body:
+{
+"user": {
+  "id": 42,
+  "created": "2024-02-15 12:33:07",
+  "note": "unbalanced } [ in a string \" {"
+},
+"items": [
+{"sku": "a-1"},
+{"sku": "b-2"}
+]
+}`
+	next := `INFO next`
+	msgs := writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	data = `2024-02-15 12:33:07 DEBUG config: {
+"listen": ":8080",
+"upstreams": ["a", "b"],
+"started": "2024-02-15 12:33:07"
+}`
+	next = `2024-02-15 12:33:08 INFO started`
+	msgs = writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	// YAML flow collections
+	data = `WARN unexpected values: [
+a, b,
+{c: d}
+]`
+	msgs = writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+
+	// an opening bracket in the middle of a message doesn't start tracking
+	data = `Traceback (most recent call last):
+  File "/app/main.py", line 3, in <module>
+    data = [
+ValueError: boom`
+	next = `INFO next`
+	msgs = writeByLine(m, data+"\n"+next, time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, next, msgs[1].Content)
+
+	// too deep documents are not tracked, so the next line starts a new message
+	data = "INFO deep\n" + strings.TrimSuffix(strings.Repeat("[\n", bracketsMaxDepth+1), "\n")
+	msgs = writeByLine(m, data+"\nnext", time.Unix(0, 0))
+	require.Len(t, msgs, 2)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, "next", msgs[1].Content)
+
+	// unbalanced brackets don't swallow the next messages
+	msgs = writeByLine(m, "INFO payload: {\nWARN cache miss\n"+`{"level":"error","msg":"boom"}`+"\n[2024-02-15 12:33:08] INFO done", time.Unix(0, 0))
+	require.Len(t, msgs, 4)
+	assert.Equal(t, "INFO payload: {", msgs[0].Content)
+	assert.Equal(t, "WARN cache miss", msgs[1].Content)
+	assert.Equal(t, `{"level":"error","msg":"boom"}`, msgs[2].Content)
+	assert.Equal(t, "[2024-02-15 12:33:08] INFO done", msgs[3].Content)
+
+	// too long documents are not tracked, the lines after the limit are split by the heuristic
+	data = "INFO long: [\n" + strings.TrimSuffix(strings.Repeat("1,\n", bracketsMaxLines), "\n")
+	msgs = writeByLine(m, data+"\n1,\n]\nnext", time.Unix(0, 0))
+	require.Len(t, msgs, 4)
+	assert.Equal(t, data, msgs[0].Content)
+	assert.Equal(t, "1,", msgs[1].Content)
+	assert.Equal(t, "]", msgs[2].Content)
+	assert.Equal(t, "next", msgs[3].Content)
+}
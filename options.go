@@ -0,0 +1,108 @@
+package logparser
+
+import (
+	"fmt"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+type Option func(*options)
+
+type options struct {
+	utf8Policy     UTF8Policy
+	charset        encoding.Encoding
+	multilineRule  *MultilineRule
+	multilineRules MultilineRules
+	levelPolicy    []LevelSource
+	levelGuesser   *LevelGuesser
+
+	maxLinesPerMessage int
+
+	stackTraceMaxFrames int
+}
+
+func newOptions(opts []Option) options {
+	o := options{levelPolicy: DefaultLevelPolicy, levelGuesser: defaultLevelGuesser}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type UTF8Policy int
+
+const (
+	UTF8PolicyDrop UTF8Policy = iota
+	UTF8PolicyReplace
+	UTF8PolicyTranscode
+)
+
+// WithUTF8Policy defines how lines that are not valid UTF-8 are handled.
+// UTF8PolicyTranscode decodes such lines from the given charset, valid UTF-8 lines are left as is.
+func WithUTF8Policy(policy UTF8Policy, charset encoding.Encoding) Option {
+	return func(o *options) {
+		o.utf8Policy = policy
+		o.charset = charset
+	}
+}
+
+// WithMultilineRule replaces the built-in multiline heuristics with the rule. A nil rule keeps the heuristics.
+func WithMultilineRule(rule *MultilineRule) Option {
+	return func(o *options) {
+		o.multilineRule = rule
+	}
+}
+
+// WithMultilineRules replaces the built-in multiline heuristics for the sources matching the rules,
+// the rule is selected by LogEntry.Source. It is only used by Parser.
+func WithMultilineRules(rules MultilineRules) Option {
+	return func(o *options) {
+		o.multilineRules = rules
+	}
+}
+
+// WithLevelPolicy defines the order in which the sources of the message level are consulted,
+// the first source providing a known level wins. Sources not listed are ignored.
+func WithLevelPolicy(sources ...LevelSource) Option {
+	return func(o *options) {
+		o.levelPolicy = sources
+	}
+}
+
+// WithLevelGuesser replaces the built-in level detectors used to guess the level from the content.
+// A nil guesser keeps the built-in detectors.
+func WithLevelGuesser(guesser *LevelGuesser) Option {
+	return func(o *options) {
+		if guesser != nil {
+			o.levelGuesser = guesser
+		}
+	}
+}
+
+// WithMaxLinesPerMessage truncates multiline messages to maxLines lines, the rest of the lines are counted as dropped.
+func WithMaxLinesPerMessage(maxLines int) Option {
+	return func(o *options) {
+		o.maxLinesPerMessage = maxLines
+	}
+}
+
+// WithStackTraceFingerprint groups messages containing Java, Python, Go or JavaScript stack traces
+// by the exception type and the top maxFrames frames instead of the message text.
+func WithStackTraceFingerprint(maxFrames int) Option {
+	return func(o *options) {
+		o.stackTraceMaxFrames = maxFrames
+	}
+}
+
+// Charset looks up an encoding by its IANA name or alias, e.g. ISO-8859-1, windows-1252 or Shift_JIS.
+func Charset(name string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported charset: %s", name)
+	}
+	return enc, nil
+}
@@ -10,22 +10,60 @@ type LogEntry struct {
 	Timestamp time.Time
 	Content   string
 	Level     Level
+	Fields    map[string]string
+
+	// LevelFixed, if set by a decoder, makes Level final regardless of the level policy,
+	// e.g. the level derived from an HTTP status
+	LevelFixed bool
+
+	// Stream is the stream the entry was written to (StreamStdout or StreamStderr) if the decoder knows it
+	Stream string
+
+	// Pattern, if set by a decoder, is used for grouping instead of a pattern built from the content.
+	Pattern string
+
+	// Source is the name of the stream (e.g. a file) the entry was read from.
+	// Entries of different sources are grouped into multiline messages separately.
+	Source string
 }
 
 type LogCounter struct {
 	Level    Level
 	Hash     string
+	Pattern  string
 	Sample   string
 	Messages int
+
+	// LevelSource is the source the level of the sample was taken from
+	LevelSource LevelSource
+
+	// Lines, Size and Truncated describe the sample before it was truncated
+	Lines     int
+	Size      int
+	Truncated bool
+
+	ExceptionType string
+	Frames        []string
+
+	// Sources is the number of messages by the source they were read from
+	Sources map[string]int
 }
 
 type Parser struct {
 	decoder Decoder
+	opts    options
 
 	patterns map[patternKey]*patternStat
 	lock     sync.RWMutex
 
-	multilineCollector *MultilineCollector
+	multilineCollectorTimeout time.Duration
+	collectors                map[string]*MultilineCollector
+	collectorsLock            sync.RWMutex
+	collectorsWg              sync.WaitGroup
+	done                      chan struct{}
+
+	entries int
+	drops   *dropCounter
 
 	stop func()
 
@@ -34,15 +72,20 @@ type Parser struct {
 
 type OnMsgCallbackF func(ts time.Time, level Level, patternHash string, msg string)
 
-func NewParser(ch <-chan LogEntry, decoder Decoder, onMsgCallback OnMsgCallbackF, multilineCollectorTimeout time.Duration) *Parser {
+func NewParser(ch <-chan LogEntry, decoder Decoder, onMsgCallback OnMsgCallbackF, multilineCollectorTimeout time.Duration, opts ...Option) *Parser {
 	p := &Parser{
 		decoder:  decoder,
 		patterns: map[patternKey]*patternStat{},
 		onMsgCb:  onMsgCallback,
+		drops:    newDropCounter(),
+		opts:     newOptions(opts),
 	}
 	ctx, stop := context.WithCancel(context.Background())
 	p.stop = stop
-	p.multilineCollector = NewMultilineCollector(ctx, multilineCollectorTimeout, multilineCollectorLimit)
+	p.multilineCollectorTimeout = multilineCollectorTimeout
+	p.collectors = map[string]*MultilineCollector{}
+	p.done = make(chan struct{})
+	p.collector(ctx, "")
 
 	go func() {
 		var err error
@@ -50,29 +93,71 @@ func NewParser(ch <-chan LogEntry, decoder Decoder, onMsgCallback OnMsgCallbackF
 			select {
 			case <-ctx.Done():
 				return
-			case entry := <-ch:
+			case entry, ok := <-ch:
+				if !ok {
+					p.closeCollectors()
+					return
+				}
+				p.lock.Lock()
+				p.entries++
+				p.lock.Unlock()
 				if p.decoder != nil {
-					if entry.Content, err = p.decoder.Decode(entry.Content); err != nil {
+					content := entry.Content
+					if entry, err = decode(p.decoder, entry); err != nil {
+						p.drops.add(DropReasonDecodeError, content)
 						continue
 					}
 				}
-				p.multilineCollector.Add(entry)
+				p.collector(ctx, entry.Source).Add(entry)
 			}
 		}
 	}()
 
+	return p
+}
+
+// collector returns the multiline collector of the source, messages of different sources are collected separately
+func (p *Parser) collector(ctx context.Context, source string) *MultilineCollector {
+	p.collectorsLock.RLock()
+	c := p.collectors[source]
+	p.collectorsLock.RUnlock()
+	if c != nil {
+		return c
+	}
+	opts := p.opts
+	if p.opts.multilineRules != nil {
+		opts.multilineRule = p.opts.multilineRules.Select(source)
+	}
+	c = newMultilineCollector(ctx, p.multilineCollectorTimeout, multilineCollectorLimit, opts)
+	p.collectorsLock.Lock()
+	p.collectors[source] = c
+	p.collectorsLock.Unlock()
+
+	p.collectorsWg.Add(1)
 	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case msg := <-p.multilineCollector.Messages:
-				p.inc(msg)
-			}
+		defer p.collectorsWg.Done()
+		for msg := range c.Messages {
+			p.inc(msg)
 		}
 	}()
+	return c
+}
 
-	return p
+// closeCollectors is called when the input channel is closed: the pending messages are counted and Done is closed
+func (p *Parser) closeCollectors() {
+	p.collectorsLock.RLock()
+	for _, c := range p.collectors {
+		c.Close()
+	}
+	p.collectorsLock.RUnlock()
+	p.collectorsWg.Wait()
+	close(p.done)
+}
+
+// Done is closed when the input channel has been closed and all the entries have been counted.
+// It is never closed if the parser is stopped before that.
+func (p *Parser) Done() <-chan struct{} {
+	return p.done
 }
 
 func (p *Parser) Stop() {
@@ -83,37 +168,39 @@ func (p *Parser) inc(msg Message) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	if msg.Level == LevelUnknown || msg.Level == LevelDebug || msg.Level == LevelInfo {
+	if msg.Level.SeverityNumber() < LevelWarning.SeverityNumber() {
 		key := patternKey{level: msg.Level, hash: ""}
 		if stat := p.patterns[key]; stat == nil {
 			p.patterns[key] = &patternStat{}
 		}
-		p.patterns[key].messages++
+		p.patterns[key].inc(msg)
 		if p.onMsgCb != nil {
 			p.onMsgCb(msg.Timestamp, msg.Level, "", msg.Content)
 		}
 		return
 	}
 
-	pattern := NewPattern(msg.Content)
+	pattern, exact, stackTrace := p.messagePattern(msg)
 	key := patternKey{level: msg.Level, hash: pattern.Hash()}
 	stat := p.patterns[key]
 	if stat == nil {
-		for k, ps := range p.patterns {
-			if k.level == msg.Level && ps.pattern.WeakEqual(pattern) {
-				stat = ps
-				break
+		if !exact {
+			for k, ps := range p.patterns {
+				if k.level == msg.Level && ps.pattern.WeakEqual(pattern) {
+					stat = ps
+					break
+				}
 			}
 		}
 		if stat == nil {
-			stat = &patternStat{pattern: pattern, sample: msg.Content}
+			stat = &patternStat{pattern: pattern, sample: msg, stackTrace: stackTrace}
 			p.patterns[key] = stat
 		}
 	}
 	if p.onMsgCb != nil {
 		p.onMsgCb(msg.Timestamp, msg.Level, key.hash, msg.Content)
 	}
-	stat.messages++
+	stat.inc(msg)
 }
 
 func (p *Parser) GetCounters() []LogCounter {
@@ -121,18 +208,81 @@ func (p *Parser) GetCounters() []LogCounter {
 	defer p.lock.RUnlock()
 	res := make([]LogCounter, 0, len(p.patterns))
 	for k, ps := range p.patterns {
-		res = append(res, LogCounter{Level: k.level, Hash: k.hash, Sample: ps.sample, Messages: ps.messages})
+		c := LogCounter{
+			Level:       k.level,
+			Hash:        k.hash,
+			Sample:      ps.sample.Content,
+			Messages:    ps.messages,
+			LevelSource: ps.sample.LevelSource,
+			Lines:       ps.sample.Lines,
+			Size:        ps.sample.Size,
+			Truncated:   ps.sample.Truncated,
+		}
+		if ps.pattern != nil {
+			c.Pattern = ps.pattern.String()
+		}
+		if ps.stackTrace != nil {
+			c.ExceptionType, c.Frames = ps.stackTrace.ExceptionType, ps.stackTrace.Frames
+		}
+		if len(ps.sources) > 0 {
+			c.Sources = make(map[string]int, len(ps.sources))
+			for s, n := range ps.sources {
+				c.Sources[s] = n
+			}
+		}
+		res = append(res, c)
 	}
 	return res
 }
 
+func (p *Parser) GetStats() Stats {
+	p.lock.RLock()
+	stats := Stats{Entries: p.entries, Dropped: map[DropReason]int{}, Samples: map[DropReason][]string{}}
+	p.lock.RUnlock()
+	p.drops.addTo(&stats)
+	p.collectorsLock.RLock()
+	for _, c := range p.collectors {
+		c.drops.addTo(&stats)
+	}
+	p.collectorsLock.RUnlock()
+	return stats
+}
+
+// messagePattern returns the pattern of the message and whether it must be matched exactly
+func (p *Parser) messagePattern(msg Message) (*Pattern, bool, *StackTrace) {
+	if msg.Pattern != "" {
+		return NewPatternFromWords(msg.Pattern), true, nil
+	}
+	if p.opts.stackTraceMaxFrames > 0 {
+		if st := parseStackTrace(msg.Content, p.opts.stackTraceMaxFrames); st != nil {
+			return st.pattern(), true, st
+		}
+	}
+	if pattern := goPanicPattern(msg.Content); pattern != nil {
+		return pattern, true, nil
+	}
+	return NewPattern(msg.Content), false, nil
+}
+
 type patternKey struct {
 	level Level
 	hash  string
 }
 
 type patternStat struct {
-	pattern  *Pattern
-	sample   string
-	messages int
+	pattern    *Pattern
+	sample     Message
+	messages   int
+	stackTrace *StackTrace
+	sources    map[string]int
+}
+
+func (ps *patternStat) inc(msg Message) {
+	ps.messages++
+	if msg.Source != "" {
+		if ps.sources == nil {
+			ps.sources = map[string]int{}
+		}
+		ps.sources[msg.Source]++
+	}
 }
@@ -0,0 +1,111 @@
+package logparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserStats(t *testing.T) {
+	ch := make(chan LogEntry)
+	p := NewParser(ch, DockerJsonDecoder{}, nil, 10*time.Millisecond)
+	defer p.Stop()
+
+	ch <- LogEntry{Content: `{"log":"ERROR foo\n"}`}
+	ch <- LogEntry{Content: `not json`}
+	ch <- LogEntry{Content: `{"log":"ERROR bar\n"}`}
+
+	assert.Eventually(t, func() bool {
+		return p.GetStats().Dropped[DropReasonDecodeError] == 1
+	}, time.Second, 10*time.Millisecond)
+	stats := p.GetStats()
+	assert.Equal(t, 3, stats.Entries)
+	assert.Equal(t, []string{"not json"}, stats.Samples[DropReasonDecodeError])
+}
+
+func TestParserStackTraceFingerprint(t *testing.T) {
+	ch := make(chan LogEntry)
+	p := NewParser(ch, nil, nil, 10*time.Millisecond, WithStackTraceFingerprint(2))
+	defer p.Stop()
+
+	traces := []string{
+		"ERROR request 1 failed\njava.lang.IllegalStateException: order 1 is closed\n\tat com.example.Orders.close(Orders.java:10)\n\tat com.example.Api.handle(Api.java:20)",
+		"ERROR request 2 failed\njava.lang.IllegalStateException: order 2 is already closed\n\tat com.example.Orders.close(Orders.java:11)\n\tat com.example.Api.handle(Api.java:25)",
+		"ERROR request 3 failed\njava.lang.NullPointerException\n\tat com.example.Orders.close(Orders.java:10)\n\tat com.example.Api.handle(Api.java:20)",
+	}
+	for _, trace := range traces {
+		p.inc(Message{Content: trace, Level: LevelError})
+	}
+
+	counters := p.GetCounters()
+	require.Len(t, counters, 2)
+	byType := map[string]LogCounter{}
+	for _, c := range counters {
+		byType[c.ExceptionType] = c
+	}
+	assert.Equal(t, 2, byType["java.lang.IllegalStateException"].Messages)
+	assert.Equal(t, []string{"com.example.Orders.close", "com.example.Api.handle"}, byType["java.lang.IllegalStateException"].Frames)
+	assert.Equal(t, 1, byType["java.lang.NullPointerException"].Messages)
+}
+
+func TestParserSources(t *testing.T) {
+	ch := make(chan LogEntry)
+	rules, err := ParseMultilineRules([]byte(`[{"source": "/var/log/worker*.log", "start": "^START"}]`))
+	require.NoError(t, err)
+	p := NewParser(ch, nil, nil, time.Minute, WithMultilineRules(rules))
+	defer p.Stop()
+
+	// the lines of the sources are interleaved, but messages are collected per source
+	for _, e := range []LogEntry{
+		{Source: "/var/log/api.log", Content: "ERROR request failed"},
+		{Source: "/var/log/worker.log", Content: "START ERROR job failed"},
+		{Source: "/var/log/api.log", Content: "\tat com.example.Api.handle(Api.java:20)"},
+		{Source: "/var/log/worker.log", Content: "ERROR caused by timeout"},
+		{Source: "/var/log/api.log", Content: "ERROR request failed"},
+		{Source: "/var/log/worker.log", Content: "START ERROR job failed"},
+		{Source: "/var/log/backup.log", Content: "ERROR request failed"},
+	} {
+		ch <- e
+	}
+	close(ch)
+
+	// the pending messages are counted once the channel is closed, without waiting for the timeout
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the parser hasn't finished")
+	}
+
+	samples := map[string]LogCounter{}
+	for _, c := range p.GetCounters() {
+		samples[c.Sample] = c
+	}
+	require.Len(t, samples, 4)
+	assert.Equal(t, map[string]int{"/var/log/api.log": 1}, samples["ERROR request failed\n\tat com.example.Api.handle(Api.java:20)"].Sources)
+	assert.Equal(t, map[string]int{"/var/log/worker.log": 1}, samples["START ERROR job failed\nERROR caused by timeout"].Sources)
+	assert.Equal(t, map[string]int{"/var/log/worker.log": 1}, samples["START ERROR job failed"].Sources)
+	assert.Equal(t, map[string]int{"/var/log/api.log": 1, "/var/log/backup.log": 1}, samples["ERROR request failed"].Sources)
+}
+
+func TestParserCounters(t *testing.T) {
+	ch := make(chan LogEntry)
+	p := NewParser(ch, nil, nil, 10*time.Millisecond)
+	defer p.Stop()
+
+	p.inc(Message{Content: "ERROR user 1 not found", Level: LevelError})
+	p.inc(Message{Content: "ERROR user 2 not found", Level: LevelError})
+	p.inc(Message{Content: "user logged in", Level: LevelInfo})
+
+	byLevel := map[Level]LogCounter{}
+	for _, c := range p.GetCounters() {
+		byLevel[c.Level] = c
+	}
+	assert.Equal(t, NewPattern("ERROR user 1 not found").String(), byLevel[LevelError].Pattern)
+	assert.Equal(t, NewPattern("ERROR user 1 not found").Hash(), byLevel[LevelError].Hash)
+	assert.Equal(t, "ERROR user 1 not found", byLevel[LevelError].Sample)
+	assert.Equal(t, 2, byLevel[LevelError].Messages)
+	assert.Equal(t, "", byLevel[LevelInfo].Pattern)
+	assert.Equal(t, 1, byLevel[LevelInfo].Messages)
+}
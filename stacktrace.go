@@ -0,0 +1,297 @@
+package logparser
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	jvmFrame     = regexp.MustCompile(`^at [^\s(]+\(.*\)`)
+	jvmMore      = regexp.MustCompile(`^\.\.\. \d+ (more|common frames omitted)`)
+	jvmException = regexp.MustCompile(`^(Exception in thread "[^"]*" )?([a-zA-Z_$][\w$]*\.)+[\w$]*(Exception|Error|Throwable)(: |$)`)
+)
+
+type jvmLineKind int
+
+const (
+	jvmLineOther jvmLineKind = iota
+	jvmLineException
+	jvmLineContinuation
+)
+
+// java.lang.IllegalStateException: message -> jvmLineException
+// at com.example.Foo.bar(Foo.java:10), ... 23 more, Caused by: ..., Suppressed: ... -> jvmLineContinuation
+// Kotlin and Scala frames have the same format: at com.example.Parser$.$anonfun$parse$1(Parser.scala:21)
+func getJvmLineKind(l string) jvmLineKind {
+	l = strings.TrimLeft(l, " \t")
+	switch {
+	case strings.HasPrefix(l, "at "):
+		if jvmFrame.MatchString(l) {
+			return jvmLineContinuation
+		}
+	case strings.HasPrefix(l, "... "):
+		if jvmMore.MatchString(l) {
+			return jvmLineContinuation
+		}
+	case strings.HasPrefix(l, "Caused by: "), strings.HasPrefix(l, "Suppressed: "), strings.HasPrefix(l, "Wrapped by: "):
+		return jvmLineContinuation
+	case strings.Contains(l, "Exception") || strings.Contains(l, "Error") || strings.Contains(l, "Throwable"):
+		if jvmException.MatchString(l) {
+			return jvmLineException
+		}
+	}
+	return jvmLineOther
+}
+
+var (
+	goPanicPrefixes = []string{"panic: ", "fatal error: ", "SIGQUIT: ", "SIGABRT: ", "SIGSEGV: ", "SIGBUS: ", "SIGILL: ", "SIGFPE: ", "SIGTRAP: "}
+	goroutineHeader = regexp.MustCompile(`^goroutine \d+( gp=\S+ m=\S+( mp=\S+)?)? \[[^\]]*\]:$`)
+	goFunctionCall  = regexp.MustCompile(`^\S+\(.*\)$`)
+	goRegister      = regexp.MustCompile(`^[a-z0-9]{2,6}\s+0x[0-9a-f]+$`)
+)
+
+const (
+	goPanicMaxFrames = 3
+)
+
+type goLineKind int
+
+const (
+	goLineOther goLineKind = iota
+	goLinePanic
+	goLineGoroutine
+	goLineContinuation
+)
+
+// Go runtime panics, fatal errors and goroutine dumps (SIGQUIT) consist of goroutine headers,
+// unindented function calls followed by tab-indented file:line lines and optional register dumps.
+func getGoLineKind(l string) goLineKind {
+	if isGoPanic(l) {
+		return goLinePanic
+	}
+	if strings.HasPrefix(l, "goroutine ") && goroutineHeader.MatchString(l) {
+		return goLineGoroutine
+	}
+	switch {
+	case strings.HasPrefix(l, "created by "), strings.HasPrefix(l, "[signal "), strings.HasPrefix(l, "PC="),
+		strings.HasPrefix(l, "exit status "), strings.HasPrefix(l, "...additional frames elided..."):
+		return goLineContinuation
+	case goFunctionCall.MatchString(l), goRegister.MatchString(l):
+		return goLineContinuation
+	}
+	return goLineOther
+}
+
+func isGoPanic(l string) bool {
+	for _, p := range goPanicPrefixes {
+		if strings.HasPrefix(l, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// goPanicPattern builds a pattern of the panic message and the top non-runtime frames of the first goroutine,
+// so that panics are grouped regardless of goroutine ids, arguments and the state of other goroutines.
+func goPanicPattern(content string) *Pattern {
+	if !isGoPanic(content) {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	pattern := NewPattern(lines[0])
+	pattern.words = append(pattern.words, goPanicFrames(lines[1:], goPanicMaxFrames)...)
+	return pattern
+}
+
+func goPanicFrames(lines []string, maxFrames int) []string {
+	var frames []string
+	var inGoroutine bool
+	for _, l := range lines {
+		if goroutineHeader.MatchString(l) {
+			if inGoroutine {
+				break
+			}
+			inGoroutine = true
+			continue
+		}
+		if !inGoroutine || l == "" || strings.HasPrefix(l, "\t") || !goFunctionCall.MatchString(l) {
+			continue
+		}
+		f := goFunctionName(l)
+		if strings.HasPrefix(f, "runtime.") || f == "panic" {
+			continue
+		}
+		frames = append(frames, f)
+		if len(frames) >= maxFrames {
+			break
+		}
+	}
+	return frames
+}
+
+// main.(*Server).handle(0x0, {0x5c1e20, 0xc000010250}) -> main.(*Server).handle
+func goFunctionName(l string) string {
+	depth := 0
+	for i := len(l) - 1; i >= 0; i-- {
+		switch l[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return l[:i]
+			}
+		}
+	}
+	return l
+}
+
+var (
+	nodeErrorSource = regexp.MustCompile(`^(file://)?[\w@.\-/\\:]+\.[cm]?[jt]s:\d+$`)
+	nodeError       = regexp.MustCompile(`^\[?(Uncaught )?[\w$.]*(Error|Exception)\b`)
+	nodeVersion     = regexp.MustCompile(`^Node\.js v\d+\.\d+\.\d+$`)
+	caret           = regexp.MustCompile(`^\s*\^+\s*$`)
+	rubyFrame       = regexp.MustCompile(`^(from )?\S+:\d+:in [` + "`" + `']`)
+)
+
+// isStackTraceContinuation reports whether the line can only be a part of a stack trace
+// even if it is not indented (e.g. because a log shipper has trimmed it):
+//
+//	Ruby:    from /app/lib/foo.rb:5:in `block in run'
+//	.NET:     ---> System.InvalidOperationException: boom
+//	         --- End of inner exception stack trace ---
+//	Node.js: Node.js v18.17.0
+func isStackTraceContinuation(l string) bool {
+	l = strings.TrimLeft(l, " \t")
+	switch {
+	case strings.HasPrefix(l, "---> "), strings.HasPrefix(l, "--- End of "):
+		return true
+	case strings.HasPrefix(l, "from "):
+		return rubyFrame.MatchString(l)
+	case strings.HasPrefix(l, "Node.js "):
+		return nodeVersion.MatchString(l)
+	case strings.HasPrefix(l, "^"):
+		return caret.MatchString(l)
+	}
+	return false
+}
+
+// Node.js prints uncaught exceptions with the source line and a caret before the error:
+//
+//	/app/server.js:12
+//	throw new Error('boom');
+//	^
+//
+//	Error: boom
+//	    at Object.<anonymous> (/app/server.js:12:11)
+func isNodeErrorSource(l string) bool {
+	return nodeErrorSource.MatchString(l)
+}
+
+func isNodeError(l string) bool {
+	return nodeError.MatchString(l)
+}
+
+var (
+	jvmFrameName      = regexp.MustCompile(`^\s*at ([^\s(]+)\(`)
+	jsFrameName       = regexp.MustCompile(`^\s*at (?:(.+) \((.+):\d+:\d+\)|(.+):\d+:\d+)$`)
+	pythonFrameName   = regexp.MustCompile(`^\s+File "([^"]+)", line \d+, in (\S+)`)
+	pythonException   = regexp.MustCompile(`^([\w.]+)(: |$)`)
+	exceptionTypeName = regexp.MustCompile(`([a-zA-Z_$][\w$]*\.)+[\w$]*(Exception|Error|Throwable)\b`)
+)
+
+type StackTrace struct {
+	ExceptionType string
+	Frames        []string
+}
+
+// parseStackTrace extracts the exception type and the top frames of a Java (and .NET), Python, Go or JavaScript
+// stack trace. Frames are normalized to function names (and file paths for Python and anonymous JS functions),
+// so the result doesn't depend on line numbers, arguments and exception messages.
+func parseStackTrace(content string, maxFrames int) *StackTrace {
+	lines := strings.Split(content, "\n")
+	if isGoPanic(content) {
+		return &StackTrace{ExceptionType: NewPattern(lines[0]).String(), Frames: goPanicFrames(lines[1:], maxFrames)}
+	}
+	for i, l := range lines {
+		if strings.Contains(l, "Traceback (most recent call last):") {
+			return parsePythonTraceback(lines[i:], maxFrames)
+		}
+		if i == 0 {
+			continue
+		}
+		if m := jvmFrameName.FindStringSubmatch(l); m != nil {
+			t := exceptionTypeName.FindString(lines[i-1])
+			if t == "" {
+				return nil
+			}
+			st := &StackTrace{ExceptionType: t}
+			for _, f := range lines[i:] {
+				if m = jvmFrameName.FindStringSubmatch(f); m == nil || len(st.Frames) >= maxFrames {
+					break
+				}
+				st.Frames = append(st.Frames, m[1])
+			}
+			return st
+		}
+		if m := jsFrameName.FindStringSubmatch(l); m != nil {
+			t, _, found := strings.Cut(strings.TrimSpace(lines[i-1]), ": ")
+			if !found {
+				return nil
+			}
+			st := &StackTrace{ExceptionType: t}
+			for _, f := range lines[i:] {
+				if m = jsFrameName.FindStringSubmatch(f); m == nil || len(st.Frames) >= maxFrames {
+					break
+				}
+				switch {
+				case m[1] != "":
+					st.Frames = append(st.Frames, m[1])
+				case m[2] != "":
+					st.Frames = append(st.Frames, m[2])
+				default:
+					st.Frames = append(st.Frames, m[3])
+				}
+			}
+			return st
+		}
+	}
+	return nil
+}
+
+// Python prints the most recent call last, so the frames are taken from the end of the last traceback.
+// The exception is the first line after the frames and their indented source lines, the lines logged after it are ignored.
+func parsePythonTraceback(lines []string, maxFrames int) *StackTrace {
+	st := &StackTrace{}
+	var frames []string
+	afterFrame := false
+	for _, l := range lines {
+		if strings.Contains(l, "Traceback (most recent call last):") {
+			frames = frames[:0]
+			continue
+		}
+		if m := pythonFrameName.FindStringSubmatch(l); m != nil {
+			frames = append(frames, m[1]+":"+m[2])
+			afterFrame = true
+			continue
+		}
+		if !afterFrame || strings.HasPrefix(l, " ") {
+			continue
+		}
+		afterFrame = false
+		if m := pythonException.FindStringSubmatch(l); m != nil {
+			st.ExceptionType = m[1]
+		}
+	}
+	if st.ExceptionType == "" {
+		return nil
+	}
+	for i := len(frames) - 1; i >= 0 && len(st.Frames) < maxFrames; i-- {
+		st.Frames = append(st.Frames, frames[i])
+	}
+	return st
+}
+
+func (st *StackTrace) pattern() *Pattern {
+	return &Pattern{words: append([]string{st.ExceptionType}, st.Frames...)}
+}
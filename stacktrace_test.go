@@ -0,0 +1,98 @@
+package logparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStackTrace(t *testing.T) {
+	st := parseStackTrace(`2023-10-04 14:27:35.249 ERROR 1 --- [nio-8080-exec-1] c.e.demo.JobController : request failed
+java.lang.IllegalStateException: Job 42 failed
+	at com.example.demo.JobService.run(JobService.kt:42) ~[classes/:na]
+	at com.example.demo.JobController.start(JobController.kt:18) ~[classes/:na]
+	at java.base/jdk.internal.reflect.NativeMethodAccessorImpl.invoke0(Native Method) ~[na:na]
+Caused by: java.io.IOException: disk full
+	at com.example.demo.Storage.write(Storage.java:10)`, 2)
+	require.NotNil(t, st)
+	assert.Equal(t, "java.lang.IllegalStateException", st.ExceptionType)
+	assert.Equal(t, []string{"com.example.demo.JobService.run", "com.example.demo.JobController.start"}, st.Frames)
+
+	st = parseStackTrace(`Unhandled exception. System.InvalidOperationException: Sequence contains no elements
+   at System.Linq.ThrowHelper.ThrowNoElementsException()
+   at Program.<Main>$(String[] args) in /src/Program.cs:line 5`, 5)
+	require.NotNil(t, st)
+	assert.Equal(t, "System.InvalidOperationException", st.ExceptionType)
+	assert.Equal(t, []string{"System.Linq.ThrowHelper.ThrowNoElementsException", "Program.<Main>$"}, st.Frames)
+
+	st = parseStackTrace(`2020-03-20 08:48:57,067 ERROR:__main__:Traceback (most recent call last):
+  File "/app/main.py", line 10, in <module>
+    func()
+  File "/app/main.py", line 4, in func
+    raise ConnectionError
+ConnectionError
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/app/main.py", line 14, in <module>
+    handle()
+  File "/app/handlers.py", line 22, in handle
+    raise RuntimeError('Failed to open database')
+RuntimeError: Failed to open database`, 5)
+	require.NotNil(t, st)
+	assert.Equal(t, "RuntimeError", st.ExceptionType)
+	assert.Equal(t, []string{"/app/handlers.py:handle", "/app/main.py:<module>"}, st.Frames)
+
+	// the lines logged after the traceback are not exceptions
+	st = parseStackTrace(`ERROR:worker:Traceback (most recent call last):
+  File "/app/worker.py", line 31, in run
+    result = job()
+             ^^^^^
+  File "/app/jobs.py", line 8, in sync
+    raise TimeoutError("upstream timed out")
+TimeoutError: upstream timed out
+Retrying
+done`, 5)
+	require.NotNil(t, st)
+	assert.Equal(t, "TimeoutError", st.ExceptionType)
+	assert.Equal(t, []string{"/app/jobs.py:sync", "/app/worker.py:run"}, st.Frames)
+
+	st = parseStackTrace(`Traceback (most recent call last):
+  File "/app/worker.py", line 31, in run
+    result = job()
+KeyboardInterrupt
+done`, 5)
+	require.NotNil(t, st)
+	assert.Equal(t, "KeyboardInterrupt", st.ExceptionType)
+
+	assert.Nil(t, parseStackTrace(`Traceback (most recent call last):
+  File "/app/worker.py", line 31, in run
+    result = job()
+[2024-01-15 10:20:30] worker stopped
+done`, 5))
+
+	st = parseStackTrace(`TypeError: Cannot read properties of undefined (reading 'value')
+    at getValue (/app/lib/util.js:4:24)
+    at /app/index.js:10:3
+    at new Promise (/app/index.js:8:10)
+    at process.processTicksAndRejections (node:internal/process/task_queues:95:5)`, 3)
+	require.NotNil(t, st)
+	assert.Equal(t, "TypeError", st.ExceptionType)
+	assert.Equal(t, []string{"getValue", "/app/index.js", "new Promise"}, st.Frames)
+
+	st = parseStackTrace(`panic: runtime error: index out of range [5] with length 3
+
+goroutine 1 [running]:
+main.lookup({0xc000012345, 0x3, 0x3}, 0x5)
+	/app/main.go:12 +0x1d
+main.main()
+	/app/main.go:40 +0x25`, 5)
+	require.NotNil(t, st)
+	assert.Equal(t, "panic runtime error index out of range with length", st.ExceptionType)
+	assert.Equal(t, []string{"main.lookup", "main.main"}, st.Frames)
+
+	assert.Nil(t, parseStackTrace(`ERROR failed to connect to db`, 5))
+	assert.Nil(t, parseStackTrace("ERROR failed\n\tat least once", 5))
+}
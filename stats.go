@@ -0,0 +1,59 @@
+package logparser
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	dropSamplesLimit   = 3
+	dropSampleMaxBytes = 256
+)
+
+type DropReason string
+
+const (
+	DropReasonDecodeError DropReason = "decode error"
+	DropReasonInvalidUtf8 DropReason = "invalid UTF-8"
+	DropReasonTruncated   DropReason = "truncated"
+)
+
+type Stats struct {
+	Entries int
+	Dropped map[DropReason]int
+	Samples map[DropReason][]string
+}
+
+type dropCounter struct {
+	lock    sync.Mutex
+	counts  map[DropReason]int
+	samples map[DropReason][]string
+}
+
+func newDropCounter() *dropCounter {
+	return &dropCounter{counts: map[DropReason]int{}, samples: map[DropReason][]string{}}
+}
+
+func (d *dropCounter) add(reason DropReason, line string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.counts[reason]++
+	if len(d.samples[reason]) >= dropSamplesLimit {
+		return
+	}
+	if len(line) > dropSampleMaxBytes {
+		line = line[:dropSampleMaxBytes]
+	}
+	d.samples[reason] = append(d.samples[reason], strings.ToValidUTF8(line, "\uFFFD"))
+}
+
+func (d *dropCounter) addTo(stats *Stats) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for reason, c := range d.counts {
+		stats.Dropped[reason] += c
+	}
+	for reason, samples := range d.samples {
+		stats.Samples[reason] = append(stats.Samples[reason], samples...)
+	}
+}
@@ -0,0 +1,148 @@
+package logparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	syslogMaxPriority = 191
+)
+
+type SyslogDecoder struct{}
+
+func (d SyslogDecoder) Decode(src string) (string, error) {
+	entry, err := d.DecodeEntry(LogEntry{Content: src})
+	if err != nil {
+		return "", err
+	}
+	return entry.Content, nil
+}
+
+// DecodeEntry supports both RFC5424 and RFC3164 (BSD) messages.
+// The <PRI> part is optional for RFC3164 to handle files written by syslog daemons, e.g. /var/log/syslog.
+func (d SyslogDecoder) DecodeEntry(entry LogEntry) (LogEntry, error) {
+	src := entry.Content
+	pri, rest, hasPri := parseSyslogPriority(src)
+	fields := map[string]string{}
+	var ok bool
+	if hasPri && len(rest) > 2 && rest[0] == '1' && rest[1] == ' ' {
+		ok = parseRFC5424(rest[2:], &entry, fields)
+	} else {
+		ok = parseRFC3164(rest, &entry, fields)
+	}
+	if !ok {
+		return entry, fmt.Errorf("unexpected syslog entry format: %s", src)
+	}
+	if hasPri {
+		fields["facility"] = strconv.Itoa(pri / 8)
+		entry.Level = LevelByPriority(strconv.Itoa(pri % 8))
+	}
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry, nil
+}
+
+func parseSyslogPriority(s string) (int, string, bool) {
+	if len(s) < 3 || s[0] != '<' {
+		return 0, s, false
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return 0, s, false
+	}
+	pri, err := strconv.Atoi(s[1:end])
+	if err != nil || pri < 0 || pri > syslogMaxPriority {
+		return 0, s, false
+	}
+	return pri, s[end+1:], true
+}
+
+// 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"] An application event log entry
+func parseRFC5424(s string, entry *LogEntry, fields map[string]string) bool {
+	var header [5]string
+	for i := range header {
+		f, rest, found := strings.Cut(s, " ")
+		if !found && i < len(header)-1 {
+			return false
+		}
+		header[i], s = f, rest
+	}
+	if header[0] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return false
+		}
+		entry.Timestamp = ts
+	}
+	for i, name := range [...]string{1: "host", 2: "app", 3: "pid", 4: "msgid"} {
+		if name != "" && header[i] != "-" {
+			fields[name] = header[i]
+		}
+	}
+	s, ok := skipStructuredData(s)
+	if !ok {
+		return false
+	}
+	entry.Content = strings.TrimPrefix(strings.TrimPrefix(s, " "), "\ufeff")
+	return true
+}
+
+func skipStructuredData(s string) (string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], true
+	}
+	var quoted bool
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ']' && !quoted:
+			if i+1 >= len(s) || s[i+1] != '[' {
+				return s[i+1:], true
+			}
+		}
+	}
+	return s, s == ""
+}
+
+// Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8
+func parseRFC3164(s string, entry *LogEntry, fields map[string]string) bool {
+	if len(s) < len(time.Stamp)+1 {
+		return false
+	}
+	ts, err := time.Parse(time.Stamp, s[:len(time.Stamp)])
+	if err != nil {
+		return false
+	}
+	year := entry.Timestamp.Year()
+	if entry.Timestamp.IsZero() {
+		year = time.Now().Year()
+	}
+	entry.Timestamp = time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), time.Local)
+	s = strings.TrimLeft(s[len(time.Stamp):], " ")
+
+	host, rest, _ := strings.Cut(s, " ")
+	if host == "" {
+		return false
+	}
+	fields["host"] = host
+	s = rest
+
+	if i := strings.Index(s, ": "); i > 0 && !strings.ContainsRune(s[:i], ' ') {
+		tag := s[:i]
+		if j := strings.IndexByte(tag, '['); j > 0 && strings.HasSuffix(tag, "]") {
+			fields["pid"] = tag[j+1 : len(tag)-1]
+			tag = tag[:j]
+		}
+		fields["app"] = tag
+		s = s[i+2:]
+	}
+	entry.Content = s
+	return true
+}
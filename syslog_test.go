@@ -0,0 +1,46 @@
+package logparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogDecoder(t *testing.T) {
+	d := SyslogDecoder{}
+
+	entry, err := d.DecodeEntry(LogEntry{Content: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event log entry`})
+	require.NoError(t, err)
+	assert.Equal(t, "An application event log entry", entry.Content)
+	assert.Equal(t, LevelInfo, entry.Level)
+	assert.Equal(t, time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), entry.Timestamp)
+	assert.Equal(t, map[string]string{"facility": "20", "host": "mymachine.example.com", "app": "evntslog", "msgid": "ID47"}, entry.Fields)
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `<11>1 - host app 42 - [a@1 x="\]"][b@2] failed to open "/etc/app.conf"`})
+	require.NoError(t, err)
+	assert.Equal(t, `failed to open "/etc/app.conf"`, entry.Content)
+	assert.Equal(t, LevelError, entry.Level)
+	assert.Equal(t, "42", entry.Fields["pid"])
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`, Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)})
+	require.NoError(t, err)
+	assert.Equal(t, `'su root' failed for lonvick on /dev/pts/8`, entry.Content)
+	assert.Equal(t, LevelCritical, entry.Level)
+	assert.Equal(t, time.Date(2020, 10, 11, 22, 14, 15, 0, time.Local), entry.Timestamp)
+	assert.Equal(t, map[string]string{"facility": "4", "host": "mymachine", "app": "su"}, entry.Fields)
+
+	entry, err = d.DecodeEntry(LogEntry{Content: `Feb  3 07:01:22 node-1 kubelet[961]: E0203 07:01:22.642736     961 reflector.go:341] watch ended`})
+	require.NoError(t, err)
+	assert.Equal(t, `E0203 07:01:22.642736     961 reflector.go:341] watch ended`, entry.Content)
+	assert.Equal(t, LevelUnknown, entry.Level)
+	assert.Equal(t, map[string]string{"host": "node-1", "app": "kubelet", "pid": "961"}, entry.Fields)
+
+	_, err = d.Decode(`2023-10-11 22:14:15 plain line`)
+	assert.Error(t, err)
+	_, err = d.Decode(`<999>1 2003-10-11T22:14:15.003Z host app - - - msg`)
+	assert.Error(t, err)
+	_, err = d.Decode(`<13>1 yesterday host app - - - msg`)
+	assert.Error(t, err)
+}
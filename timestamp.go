@@ -1,9 +1,37 @@
 package logparser
 
+import (
+	"strconv"
+	"time"
+)
+
 const (
 	lookForTimestampLimit = 100
 )
 
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts, true
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 {
+		if f > 1e12 {
+			f /= 1000
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+	}
+	return time.Time{}, false
+}
+
 func containsTimestamp(line string) bool {
 	if len(line) > lookForTimestampLimit {
 		line = line[:lookForTimestampLimit]